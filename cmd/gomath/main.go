@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
+	"github.com/gomath/gomath/internal/auth"
+	"github.com/gomath/gomath/internal/cache"
 	"github.com/gomath/gomath/internal/config"
 	"github.com/gomath/gomath/internal/explanation"
 	"github.com/gomath/gomath/internal/history"
 	"github.com/gomath/gomath/internal/http"
+	"github.com/gomath/gomath/internal/media"
 	"github.com/gomath/gomath/internal/ocr"
+	"github.com/gomath/gomath/internal/ratelimit"
+	"github.com/gomath/gomath/internal/tutor"
+	"github.com/gomath/gomath/internal/usage"
 )
 
 func main() {
@@ -23,8 +32,13 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Println(models.Status())
+	usage.LoadEncoding()
 	ocrSvc := ocr.NewService(models.OCR)
 	explainGen := explanation.NewGenerator(models.LLM.Explanation)
+	// 识图与解析共用一个并发限制，同一上游（provider + api_base）合并计数
+	llmSlots := ratelimit.NewConcurrency(models.RateLimit.LLMConcurrency())
+	ocrSvc.SetConcurrency(llmSlots)
+	explainGen.SetConcurrency(llmSlots)
 	explainStore := explanation.NewStore()
 	// 讲解图生成：暂用 nil，后续接入文生图 API 后注入
 	var imageGen http.StepImageGenerator = nil
@@ -42,20 +56,111 @@ func main() {
 		fmt.Fprintf(os.Stderr, "history path: %v\n", err)
 		os.Exit(1)
 	}
-	historyStore, err := history.NewStore(historyAbsPath)
+	historyStore, err := history.NewStore(historyAbsPath, models.History)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "history store: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Println("history file:", historyAbsPath)
+	tutorFilePath := os.Getenv("GOMATH_TUTOR_FILE")
+	if tutorFilePath == "" {
+		tutorFilePath = filepath.Join(filepath.Dir(historyAbsPath), "tutor.json")
+	}
+	tutorStore, err := tutor.NewStore(tutorFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tutor store: %v\n", err)
+		os.Exit(1)
+	}
+
+	cacheFilePath := os.Getenv("GOMATH_CACHE_FILE")
+	if cacheFilePath == "" {
+		cacheFilePath = filepath.Join(filepath.Dir(historyAbsPath), "cache.json")
+	}
+	resultCache, err := cache.NewStore(cacheFilePath, models.Cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cache store: %v\n", err)
+		os.Exit(1)
+	}
+	usageFilePath := os.Getenv("GOMATH_USAGE_FILE")
+	if usageFilePath == "" {
+		usageFilePath = filepath.Join(filepath.Dir(historyAbsPath), "usage.jsonl")
+	}
+	usageLedger, err := usage.NewLedger(usageFilePath, models.Pricing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage ledger: %v\n", err)
+		os.Exit(1)
+	}
+
+	var authStore *auth.Store
+	if models.Auth.Enabled {
+		authFilePath := os.Getenv("GOMATH_AUTH_FILE")
+		if authFilePath == "" {
+			authFilePath = filepath.Join(filepath.Dir(historyAbsPath), "auth.json")
+		}
+		authStore, err = auth.NewStore(authFilePath, models.Auth.SessionTTL())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "auth store: %v\n", err)
+			os.Exit(1)
+		}
+		// 首次启动（尚无用户）时按配置创建管理员
+		if authStore.UserCount() == 0 {
+			name, pass := models.Auth.AdminUsername, models.Auth.AdminPass()
+			if name == "" || pass == "" {
+				fmt.Fprintln(os.Stderr, "auth: no users yet, set auth.admin_username and auth.admin_password (or admin_password_env) to create the first admin")
+				os.Exit(1)
+			}
+			if _, err := authStore.CreateUser(name, pass, true); err != nil {
+				fmt.Fprintf(os.Stderr, "auth: create admin: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("auth: created admin user", name)
+		}
+		fmt.Println("auth file:", authFilePath)
+	} else {
+		fmt.Println("WARNING: auth disabled, all API endpoints and data are open to anyone who can reach the server")
+	}
 
 	srv := http.NewServer(uploadDir, 10, ocrSvc, explainGen, explainStore, imageGen, historyStore)
+	srv.Transcriber = ocrSvc
+	srv.Splitter = ocrSvc
+	srv.Media = media.NewProcessor(models.Media)
+	srv.Grader = explainGen
+	srv.Tutor = explainGen
+	srv.TutorStore = tutorStore
+	srv.Usage = usageLedger
+	srv.TrustProxy = models.RateLimit.TrustProxy
+	if !models.RateLimit.Disabled {
+		srv.Limiter = ratelimit.NewSet(models.RateLimit)
+	}
+	if !models.Cache.Disabled {
+		srv.Cache = resultCache
+	}
+	if authStore != nil {
+		srv.Auth = authStore
+	}
+	go srv.RunRetention(context.Background(), models.History.PruneInterval())
 	addr := os.Getenv("GOMATH_ADDR")
 	if addr == "" {
 		addr = ":8080"
 	}
+	// 退出前写入尚未落盘的历史与缓存修改
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		if err := resultCache.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "cache save: %v\n", err)
+		}
+		if err := historyStore.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "history save: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
 	fmt.Println("gomath server listening on", addr)
 	if err := srv.Run(addr); err != nil {
+		resultCache.Close()
+		historyStore.Close()
 		fmt.Fprintf(os.Stderr, "server: %v\n", err)
 		os.Exit(1)
 	}
@@ -3,10 +3,12 @@ module github.com/gomath/gomath
 go 1.24.4
 
 require (
-	github.com/dlclark/regexp2 v1.10.0 // indirect
-	github.com/go-chi/chi/v5 v5.2.5 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
-	github.com/tmc/langchaingo v0.1.14 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/go-chi/chi/v5 v5.2.5
+	github.com/google/uuid v1.6.0
+	github.com/pkoukk/tiktoken-go v0.1.6
+	github.com/tmc/langchaingo v0.1.14
+	golang.org/x/image v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/dlclark/regexp2 v1.10.0 // indirect
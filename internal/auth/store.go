@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomath/gomath/internal/persist"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUserExists 用户名已被使用
+	ErrUserExists = errors.New("username already exists")
+	// ErrInvalidUsername 用户名不合法
+	ErrInvalidUsername = errors.New("username must be 3-32 letters, digits, '_', '-' or '.'")
+	// ErrWeakPassword 密码过短
+	ErrWeakPassword = errors.New("password must be at least 8 characters")
+)
+
+// 令牌前缀：API Key 以 gmk_ 开头，会话令牌以 gms_ 开头
+const (
+	apiKeyPrefix  = "gmk_"
+	sessionPrefix = "gms_"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// User 用户
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Admin        bool   `json:"admin"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// APIKey 用户的 API Key，只保存哈希；Prefix 为明文前几位，便于用户辨认
+type APIKey struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"`
+	Hash      string `json:"hash"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// session 登录会话，只保存令牌哈希
+type session struct {
+	Hash      string `json:"hash"`
+	UserID    string `json:"user_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// fileData 持久化文件结构
+type fileData struct {
+	Users    []*User    `json:"users"`
+	APIKeys  []*APIKey  `json:"api_keys"`
+	Sessions []*session `json:"sessions"`
+}
+
+// Store 用户、API Key 与会话存储，内存 + 文件持久化；所有密钥只保存哈希
+type Store struct {
+	mu         sync.RWMutex
+	users      map[string]*User   // id → 用户
+	byName     map[string]*User   // 小写用户名 → 用户
+	keys       map[string]*APIKey // 哈希 → Key
+	sessions   map[string]*session
+	sessionTTL time.Duration
+	filePath   string
+	saver      *persist.Saver // 串行化写入，仅内存时为 nil
+}
+
+// NewStore 创建存储，filePath 为空则仅内存；sessionTTL 为登录会话有效期
+func NewStore(filePath string, sessionTTL time.Duration) (*Store, error) {
+	s := &Store{
+		users:      make(map[string]*User),
+		byName:     make(map[string]*User),
+		keys:       make(map[string]*APIKey),
+		sessions:   make(map[string]*session),
+		sessionTTL: sessionTTL,
+		filePath:   filePath,
+	}
+	if filePath != "" {
+		if err := s.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		s.saver = persist.NewSaver("auth", 0, s.write)
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var fd fileData
+	if err := json.Unmarshal(data, &fd); err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range fd.Users {
+		if u != nil {
+			s.users[u.ID] = u
+			s.byName[strings.ToLower(u.Username)] = u
+		}
+	}
+	for _, k := range fd.APIKeys {
+		if k != nil {
+			s.keys[k.Hash] = k
+		}
+	}
+	for _, sess := range fd.Sessions {
+		if sess != nil && sess.ExpiresAt > now {
+			s.sessions[sess.Hash] = sess
+		}
+	}
+	return nil
+}
+
+// write 将用户、Key 与未过期会话写入临时文件后原子替换；含密码哈希，仅本用户可读
+func (s *Store) write() error {
+	now := time.Now().UnixMilli()
+	s.mu.RLock()
+	fd := fileData{Users: make([]*User, 0, len(s.users)), APIKeys: make([]*APIKey, 0, len(s.keys)), Sessions: make([]*session, 0, len(s.sessions))}
+	for _, u := range s.users {
+		cp := *u
+		fd.Users = append(fd.Users, &cp)
+	}
+	for _, k := range s.keys {
+		cp := *k
+		fd.APIKeys = append(fd.APIKeys, &cp)
+	}
+	for _, sess := range s.sessions {
+		if sess.ExpiresAt > now {
+			cp := *sess
+			fd.Sessions = append(fd.Sessions, &cp)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(fd.Users, func(i, j int) bool { return fd.Users[i].CreatedAt < fd.Users[j].CreatedAt })
+	sort.Slice(fd.APIKeys, func(i, j int) bool { return fd.APIKeys[i].CreatedAt < fd.APIKeys[j].CreatedAt })
+	data, err := json.MarshalIndent(fd, "", "  ")
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(s.filePath, data, 0600, false)
+}
+
+// CreateUser 新建用户
+func (s *Store) CreateUser(username, password string, admin bool) (*User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if len(password) < 8 {
+		return nil, ErrWeakPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	u := &User{ID: uuid.New().String(), Username: username, PasswordHash: hash, Admin: admin, CreatedAt: time.Now().UnixMilli()}
+	s.mu.Lock()
+	if _, ok := s.byName[strings.ToLower(username)]; ok {
+		s.mu.Unlock()
+		return nil, ErrUserExists
+	}
+	s.users[u.ID] = u
+	s.byName[strings.ToLower(username)] = u
+	s.mu.Unlock()
+	if err := s.saver.Save(); err != nil {
+		log.Printf("[auth] save after CreateUser: %v", err)
+	}
+	cp := *u
+	return &cp, nil
+}
+
+// UserCount 返回用户数，用于首次启动时创建管理员
+func (s *Store) UserCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+// 用户不存在时也做一次哈希比较，避免通过响应时间判断用户名是否存在
+var dummyHash, _ = hashPassword("gomath-dummy-password")
+
+// Login 校验用户名密码，成功时创建会话并返回会话令牌（仅此一次返回明文）
+func (s *Store) Login(username, password string) (string, *User, error) {
+	s.mu.RLock()
+	u, ok := s.byName[strings.ToLower(username)]
+	var hash string
+	if ok {
+		hash = u.PasswordHash
+	}
+	s.mu.RUnlock()
+	if !ok {
+		checkPassword(dummyHash, password)
+		return "", nil, ErrInvalidCredentials
+	}
+	if !checkPassword(hash, password) {
+		return "", nil, ErrInvalidCredentials
+	}
+	token, err := newSecret(sessionPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	s.mu.Lock()
+	s.sessions[hashSecret(token)] = &session{Hash: hashSecret(token), UserID: u.ID, ExpiresAt: time.Now().Add(s.sessionTTL).UnixMilli()}
+	cp := *u
+	s.mu.Unlock()
+	if err := s.saver.Save(); err != nil {
+		log.Printf("[auth] save after Login: %v", err)
+	}
+	return token, &cp, nil
+}
+
+// Logout 使会话令牌失效
+func (s *Store) Logout(token string) {
+	s.mu.Lock()
+	_, ok := s.sessions[hashSecret(token)]
+	delete(s.sessions, hashSecret(token))
+	s.mu.Unlock()
+	if ok {
+		if err := s.saver.Save(); err != nil {
+			log.Printf("[auth] save after Logout: %v", err)
+		}
+	}
+}
+
+// Authenticate 按 API Key 或会话令牌识别用户
+func (s *Store) Authenticate(token string) (*User, bool) {
+	if token == "" {
+		return nil, false
+	}
+	h := hashSecret(token)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var userID string
+	if k, ok := s.keys[h]; ok {
+		userID = k.UserID
+	} else if sess, ok := s.sessions[h]; ok && sess.ExpiresAt > time.Now().UnixMilli() {
+		userID = sess.UserID
+	} else {
+		return nil, false
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, false
+	}
+	cp := *u
+	return &cp, true
+}
+
+// CreateAPIKey 为用户创建 API Key，返回明文（仅此一次）与记录
+func (s *Store) CreateAPIKey(userID, name string) (string, *APIKey, error) {
+	s.mu.RLock()
+	_, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return "", nil, errors.New("user not found")
+	}
+	secret, err := newSecret(apiKeyPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	k := &APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(apiKeyPrefix)+6],
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	s.mu.Lock()
+	s.keys[k.Hash] = k
+	s.mu.Unlock()
+	if err := s.saver.Save(); err != nil {
+		log.Printf("[auth] save after CreateAPIKey: %v", err)
+	}
+	cp := *k
+	return secret, &cp, nil
+}
+
+// ListAPIKeys 列出用户的 API Key（不含明文），按创建时间排序
+func (s *Store) ListAPIKeys(userID string) []APIKey {
+	s.mu.RLock()
+	out := make([]APIKey, 0)
+	for _, k := range s.keys {
+		if k.UserID == userID {
+			out = append(out, *k)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out
+}
+
+// DeleteAPIKey 删除用户的某个 API Key
+func (s *Store) DeleteAPIKey(userID, id string) bool {
+	s.mu.Lock()
+	var found bool
+	for h, k := range s.keys {
+		if k.ID == id && k.UserID == userID {
+			delete(s.keys, h)
+			found = true
+			break
+		}
+	}
+	s.mu.Unlock()
+	if found {
+		if err := s.saver.Save(); err != nil {
+			log.Printf("[auth] save after DeleteAPIKey: %v", err)
+		}
+	}
+	return found
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	s, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.CreateUser("alice", "correct horse", false)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser("Alice", "another pass", false); err != ErrUserExists {
+		t.Errorf("duplicate username: err = %v, want ErrUserExists", err)
+	}
+	if _, err := s.CreateUser("bob", "short", false); err != ErrWeakPassword {
+		t.Errorf("short password: err = %v, want ErrWeakPassword", err)
+	}
+	if _, _, err := s.Login("alice", "wrong password"); err != ErrInvalidCredentials {
+		t.Errorf("wrong password: err = %v", err)
+	}
+	token, _, err := s.Login("alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	key, rec, err := s.CreateAPIKey(u.ID, "script")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(key, rec.Prefix) || rec.Hash == key {
+		t.Errorf("api key record = %+v", rec)
+	}
+
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("auth file mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	// 重新加载后会话与 Key 仍有效，文件中不含明文
+	s2, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range []string{token, key} {
+		if got, ok := s2.Authenticate(tok); !ok || got.ID != u.ID {
+			t.Errorf("Authenticate(%q) = %v, %v", tok[:8], got, ok)
+		}
+	}
+	s2.Logout(token)
+	if _, ok := s2.Authenticate(token); ok {
+		t.Error("session still valid after Logout")
+	}
+	if !s2.DeleteAPIKey(u.ID, rec.ID) {
+		t.Fatal("DeleteAPIKey failed")
+	}
+	if _, ok := s2.Authenticate(key); ok {
+		t.Error("api key still valid after delete")
+	}
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/persist"
+)
+
+// saveDelay Put 后等待合并写入的时间：缓存丢失只需重新调用模型，不必每次 Put 都重写整个文件
+const saveDelay = time.Second
+
+// entry 缓存条目：值以 JSON 保存，取出时解码为调用方类型
+type entry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	At    int64           `json:"at"`
+}
+
+// KindStats 某类缓存的命中统计（进程启动以来）与当前条目数
+type KindStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+	Entries int     `json:"entries"`
+}
+
+// Stats 缓存整体统计，按类别（ocr、explain-text 等）分组
+type Stats struct {
+	Entries    int                  `json:"entries"`
+	MaxEntries int                  `json:"max_entries"`
+	TTLHours   float64              `json:"ttl_hours"`
+	Kinds      map[string]KindStats `json:"kinds"`
+}
+
+// Store 模型结果缓存（识图文本、解析结果等），内存 + 文件持久化；超过有效期的条目视为未命中
+type Store struct {
+	mu         sync.RWMutex
+	entries    map[string]*entry
+	filePath   string
+	maxEntries int
+	ttl        time.Duration
+	hits       map[string]int64
+	misses     map[string]int64
+
+	saver *persist.Saver // 合并写入，仅内存时为 nil
+}
+
+// NewStore 根据统一配置中的 cache 块创建缓存，filePath 为空则仅内存
+func NewStore(filePath string, cfg config.CacheConfig) (*Store, error) {
+	s := &Store{
+		entries:    make(map[string]*entry),
+		filePath:   filePath,
+		maxEntries: cfg.MaxEntryCount(),
+		ttl:        cfg.TTL(),
+		hits:       make(map[string]int64),
+		misses:     make(map[string]int64),
+	}
+	if filePath != "" {
+		if err := s.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		s.saver = persist.NewSaver("cache", saveDelay, s.write)
+	}
+	return s, nil
+}
+
+// Key 由类别与多个组成部分（内容哈希、模型、prompt 版本等）生成缓存键，形如 <kind>:<sha256>
+func Key(kind string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// kindOf 返回缓存键的类别
+func kindOf(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return "other"
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var list []*entry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for _, e := range list {
+		if e != nil && e.Key != "" {
+			s.entries[e.Key] = e
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Flush 立即写入尚未落盘的修改
+func (s *Store) Flush() error {
+	return s.saver.Flush()
+}
+
+// Close 写入尚未落盘的修改，之后的修改立即写入；进程退出前调用
+func (s *Store) Close() error {
+	return s.saver.Close()
+}
+
+// write 将当前全部条目写入临时文件后原子替换
+func (s *Store) write() error {
+	s.mu.RLock()
+	snapshot := make([]entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		snapshot = append(snapshot, *e)
+	}
+	s.mu.RUnlock()
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].At > snapshot[j].At })
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := persist.WriteFile(s.filePath, data, 0644, false); err != nil {
+		return fmt.Errorf("write %s: %w", s.filePath, err)
+	}
+	return nil
+}
+
+// Get 按键取出缓存值并解码到 v，未命中、已过期或解码失败返回 false
+func (s *Store) Get(key string, v any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kind := kindOf(key)
+	e, ok := s.entries[key]
+	if ok && s.expired(e, time.Now()) {
+		delete(s.entries, key)
+		ok = false
+	}
+	if ok && json.Unmarshal(e.Value, v) == nil {
+		s.hits[kind]++
+		return true
+	}
+	s.misses[kind]++
+	return false
+}
+
+// Stats 返回命中统计与条目数
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := Stats{Entries: len(s.entries), MaxEntries: s.maxEntries, TTLHours: s.ttl.Hours(), Kinds: make(map[string]KindStats)}
+	counts := make(map[string]int)
+	for key := range s.entries {
+		counts[kindOf(key)]++
+	}
+	kinds := make(map[string]bool)
+	for k := range counts {
+		kinds[k] = true
+	}
+	for k := range s.hits {
+		kinds[k] = true
+	}
+	for k := range s.misses {
+		kinds[k] = true
+	}
+	for k := range kinds {
+		ks := KindStats{Hits: s.hits[k], Misses: s.misses[k], Entries: counts[k]}
+		if total := ks.Hits + ks.Misses; total > 0 {
+			ks.HitRate = float64(ks.Hits) / float64(total)
+		}
+		st.Kinds[k] = ks
+	}
+	return st
+}
+
+func (s *Store) expired(e *entry, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(time.UnixMilli(e.At)) > s.ttl
+}
+
+// Put 写入缓存，超出容量时淘汰最旧的条目
+func (s *Store) Put(key string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[cache] marshal %s: %v", key, err)
+		return
+	}
+	s.mu.Lock()
+	s.entries[key] = &entry{Key: key, Value: data, At: time.Now().UnixMilli()}
+	s.evictLocked()
+	s.mu.Unlock()
+	s.saver.Changed()
+}
+
+// evictLocked 删除过期条目，仍超出容量时删除最旧的条目，调用方需持有写锁
+func (s *Store) evictLocked() {
+	now := time.Now()
+	for key, e := range s.entries {
+		if s.expired(e, now) {
+			delete(s.entries, key)
+		}
+	}
+	over := len(s.entries) - s.maxEntries
+	if over <= 0 {
+		return
+	}
+	list := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].At < list[j].At })
+	for _, e := range list[:over] {
+		delete(s.entries, e.Key)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+func TestPutPersistsOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	s, err := NewStore(path, config.CacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		s.Put(Key("ocr", v), v)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewStore(path, config.CacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if !reloaded.Get(Key("ocr", "c"), &got) || got != "c" {
+		t.Fatalf("reloaded Get = %q, want c", got)
+	}
+	if st := reloaded.Stats(); st.Entries != 3 {
+		t.Fatalf("reloaded entries = %d, want 3", st.Entries)
+	}
+}
@@ -8,9 +8,15 @@ import (
 // Models 从统一配置文件 config/models.yaml 加载的完整配置。
 // 内含 ocr、llm、video 三块，分别供识图、解析、视频模块使用。
 type Models struct {
-	OCR   OCRConfig    `yaml:"ocr"`
-	LLM   LLMConfig    `yaml:"llm"`
-	Video VideoConfig  `yaml:"video"`
+	OCR       OCRConfig       `yaml:"ocr"`
+	LLM       LLMConfig       `yaml:"llm"`
+	Video     VideoConfig     `yaml:"video"`
+	Media     MediaConfig     `yaml:"media"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Pricing   PricingConfig   `yaml:"pricing"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Auth      AuthConfig      `yaml:"auth"`
+	History   HistoryConfig   `yaml:"history"`
 }
 
 // OCRConfig OCR 识图配置：图片 → 题目文本
@@ -57,6 +63,8 @@ type LLMExplanationConfig struct {
 	APIKeyEnv         string  `yaml:"api_key_env"`   // 可选：api_key 为空时从该环境变量读取
 	Temperature       float64 `yaml:"temperature"`
 	MaxTokens         int     `yaml:"max_tokens"`
+	MaxInputTokens    int     `yaml:"max_input_tokens"` // 题目文本最大 token 数，超出直接拒绝，≤0 时默认 2000
+	ContextWindow     int     `yaml:"context_window"`   // 模型上下文窗口（输入 + 输出 token），≤0 时默认 32768
 	TimeoutSec        int     `yaml:"timeout_sec"`   // 单次解析请求超时（秒），≤0 时默认 180
 	SystemPromptFile  string  `yaml:"system_prompt_file"`
 }
@@ -80,6 +88,22 @@ func (c LLMExplanationConfig) Timeout() time.Duration {
 	return time.Duration(c.TimeoutSec) * time.Second
 }
 
+// MaxInputTokenCount 返回题目文本最大 token 数
+func (c LLMExplanationConfig) MaxInputTokenCount() int {
+	if c.MaxInputTokens <= 0 {
+		return 2000
+	}
+	return c.MaxInputTokens
+}
+
+// ContextWindowTokens 返回模型上下文窗口大小
+func (c LLMExplanationConfig) ContextWindowTokens() int {
+	if c.ContextWindow <= 0 {
+		return 32768
+	}
+	return c.ContextWindow
+}
+
 // VideoConfig 视频生成配置（Phase 2 后续待办）
 type VideoConfig struct {
 	TTSProvider             string `yaml:"tts_provider"`
@@ -87,3 +111,241 @@ type VideoConfig struct {
 	FFmpegBin               string `yaml:"ffmpeg_bin"`
 	DefaultStepDurationSec   int    `yaml:"default_step_duration_sec"`
 }
+
+// MediaConfig 图片预处理配置：上传图片送入识图/解析模型前统一做方向校正、去 EXIF、缩放与重编码
+type MediaConfig struct {
+	Disabled     bool    `yaml:"disabled"`      // true 时直接使用原图
+	MaxEdge      int     `yaml:"max_edge"`      // 长边最大像素，≤0 时默认 2048
+	JPEGQuality  int     `yaml:"jpeg_quality"`  // JPEG 重编码质量，≤0 时默认 85
+	Grayscale    bool    `yaml:"grayscale"`     // 转为灰度图
+	Contrast     float64 `yaml:"contrast"`      // 对比度增强系数，≤1 表示不增强（如 1.3）
+	MaxDimension int     `yaml:"max_dimension"` // 上传图片单边最大像素，≤0 时默认 12000
+	MaxPixels    int64   `yaml:"max_pixels"`    // 上传图片最大像素数（防解压炸弹），≤0 时默认 5000 万
+}
+
+// MaxEdgePixels 返回长边最大像素，≤0 时默认 2048
+func (c MediaConfig) MaxEdgePixels() int {
+	if c.MaxEdge <= 0 {
+		return 2048
+	}
+	return c.MaxEdge
+}
+
+// MaxDimensionPixels 返回上传图片单边最大像素，≤0 时默认 12000
+func (c MediaConfig) MaxDimensionPixels() int {
+	if c.MaxDimension <= 0 {
+		return 12000
+	}
+	return c.MaxDimension
+}
+
+// MaxPixelCount 返回上传图片最大像素数，≤0 时默认 5000 万
+func (c MediaConfig) MaxPixelCount() int64 {
+	if c.MaxPixels <= 0 {
+		return 50_000_000
+	}
+	return c.MaxPixels
+}
+
+// Quality 返回 JPEG 质量（1~100），≤0 时默认 85
+func (c MediaConfig) Quality() int {
+	if c.JPEGQuality <= 0 {
+		return 85
+	}
+	if c.JPEGQuality > 100 {
+		return 100
+	}
+	return c.JPEGQuality
+}
+
+// CacheConfig 模型结果缓存配置：识图文本与解析结果按图片内容或规范化题目文本缓存
+type CacheConfig struct {
+	Disabled   bool `yaml:"disabled"`    // true 时不缓存
+	TTLHours   int  `yaml:"ttl_hours"`   // 条目有效期（小时），≤0 时默认 720（30 天）
+	MaxEntries int  `yaml:"max_entries"` // 最多缓存条数，≤0 时默认 2000
+}
+
+// TTL 返回缓存条目有效期
+func (c CacheConfig) TTL() time.Duration {
+	if c.TTLHours <= 0 {
+		return 720 * time.Hour
+	}
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+// MaxEntryCount 返回最多缓存条数
+func (c CacheConfig) MaxEntryCount() int {
+	if c.MaxEntries <= 0 {
+		return 2000
+	}
+	return c.MaxEntries
+}
+
+// PricingConfig 模型价格表，用于按 token 用量估算费用
+type PricingConfig struct {
+	Currency string                `yaml:"currency"` // 计价币种，为空时默认 CNY
+	Models   map[string]ModelPrice `yaml:"models"`   // 模型名 → 价格，未列出的模型费用记为 0
+}
+
+// ModelPrice 单个模型的价格（每百万 token）
+type ModelPrice struct {
+	InputPerMillion  float64 `yaml:"input_per_million"`
+	OutputPerMillion float64 `yaml:"output_per_million"`
+}
+
+// CurrencyCode 返回计价币种
+func (c PricingConfig) CurrencyCode() string {
+	if c.Currency == "" {
+		return "CNY"
+	}
+	return c.Currency
+}
+
+// Cost 按价格表计算一次调用的费用
+func (c PricingConfig) Cost(model string, promptTokens, completionTokens int) float64 {
+	p, ok := c.Models[model]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// RateLimitConfig 限流配置：按客户端（API Key 或 IP）与接口类别做令牌桶限流，并限制每个上游的模型并发数
+type RateLimitConfig struct {
+	Disabled         bool      `yaml:"disabled"`           // 关闭按客户端限流，上游并发限制不受影响
+	TrustProxy       bool      `yaml:"trust_proxy"`        // 部署在反向代理后时以 X-Forwarded-For 首个地址识别客户端，导出链接采用 X-Forwarded-Proto / -Host
+	Upload           RateLimit `yaml:"upload"`             // 上传图片，默认每分钟 30 次、突发 10
+	OCR              RateLimit `yaml:"ocr"`                // 识图，默认每分钟 20 次、突发 5
+	Explain          RateLimit `yaml:"explain"`            // 解析/批改/辅导，默认每分钟 10 次、突发 3
+	Login            RateLimit `yaml:"login"`              // 登录（按 IP），默认每分钟 10 次、突发 5，限制暴力猜测密码
+	MaxConcurrentLLM int       `yaml:"max_concurrent_llm"` // 每个上游（provider + api_base）同时进行的模型调用数，≤0 时默认 8
+}
+
+// RateLimit 单类接口的令牌桶参数
+type RateLimit struct {
+	PerMinute float64 `yaml:"per_minute"` // 每分钟补充的请求数
+	Burst     int     `yaml:"burst"`      // 桶容量（允许的突发请求数）
+}
+
+// Class 返回某类接口（upload、ocr、explain、login）的限流参数，未配置的项使用默认值
+func (c RateLimitConfig) Class(class string) RateLimit {
+	var l, def RateLimit
+	switch class {
+	case "upload":
+		l, def = c.Upload, RateLimit{PerMinute: 30, Burst: 10}
+	case "ocr":
+		l, def = c.OCR, RateLimit{PerMinute: 20, Burst: 5}
+	case "explain":
+		l, def = c.Explain, RateLimit{PerMinute: 10, Burst: 3}
+	case "login":
+		l, def = c.Login, RateLimit{PerMinute: 10, Burst: 5}
+	default:
+		return RateLimit{}
+	}
+	if l.PerMinute <= 0 {
+		l.PerMinute = def.PerMinute
+	}
+	if l.Burst <= 0 {
+		l.Burst = def.Burst
+	}
+	return l
+}
+
+// LLMConcurrency 返回每个上游的模型并发上限
+func (c RateLimitConfig) LLMConcurrency() int {
+	if c.MaxConcurrentLLM <= 0 {
+		return 8
+	}
+	return c.MaxConcurrentLLM
+}
+
+// AuthConfig 鉴权配置：用户名密码登录（会话 Cookie）与 API Key，开启后历史、结果、上传按用户隔离
+type AuthConfig struct {
+	Enabled          bool   `yaml:"enabled"`            // 默认关闭（所有接口开放，数据不隔离）
+	AdminUsername    string `yaml:"admin_username"`     // 首次启动（尚无用户）时创建的管理员
+	AdminPassword    string `yaml:"admin_password"`     // 优先使用：直接从配置文件读取
+	AdminPasswordEnv string `yaml:"admin_password_env"` // 可选：admin_password 为空时从该环境变量读取
+	SessionTTLHours  int    `yaml:"session_ttl_hours"`  // 登录会话有效期（小时），≤0 时默认 168（7 天）
+}
+
+// AdminPass 返回初始管理员密码：优先使用配置文件中的 admin_password，否则从 admin_password_env 环境变量读取。
+func (c AuthConfig) AdminPass() string {
+	if c.AdminPassword != "" {
+		return c.AdminPassword
+	}
+	if c.AdminPasswordEnv != "" {
+		return os.Getenv(c.AdminPasswordEnv)
+	}
+	return ""
+}
+
+// SessionTTL 返回登录会话有效期
+func (c AuthConfig) SessionTTL() time.Duration {
+	if c.SessionTTLHours <= 0 {
+		return 168 * time.Hour
+	}
+	return time.Duration(c.SessionTTLHours) * time.Hour
+}
+
+// HistoryConfig 历史保留策略：超出条数或过期的条目定期清理，连同不再被引用的上传图片与解析结果
+type HistoryConfig struct {
+	MaxItems             int  `yaml:"max_items"`              // 最多保留条数（所有用户合计），≤0 时默认 1000
+	MaxItemsPerUser      int  `yaml:"max_items_per_user"`     // 每个用户最多保留条数，≤0 表示不限
+	MaxAgeDays           int  `yaml:"max_age_days"`           // 条目保留天数，≤0 表示不限
+	PrunePinned          bool `yaml:"prune_pinned"`           // true 时置顶条目与错题也参与清理，默认二者永不过期
+	PruneIntervalMinutes int  `yaml:"prune_interval_minutes"` // 清理间隔（分钟），≤0 时默认 60
+
+	// 持久化：修改后等待 save_delay_ms 合并写入，写临时文件后原子替换；文件损坏时从最近的完好备份恢复
+	SaveDelayMs           int  `yaml:"save_delay_ms"`           // 合并写入的等待时间（毫秒），≤0 时默认 200
+	Fsync                 bool `yaml:"fsync"`                   // 写入后 fsync 文件与目录，断电也不丢失已写入的内容，写入变慢
+	Backups               int  `yaml:"backups"`                 // 轮换保留的备份份数，≤0 时默认 3
+	BackupIntervalMinutes int  `yaml:"backup_interval_minutes"` // 两次备份的最小间隔（分钟），≤0 时默认 60
+}
+
+// SaveDelay 返回合并写入的等待时间
+func (c HistoryConfig) SaveDelay() time.Duration {
+	if c.SaveDelayMs <= 0 {
+		return 200 * time.Millisecond
+	}
+	return time.Duration(c.SaveDelayMs) * time.Millisecond
+}
+
+// BackupCount 返回备份份数
+func (c HistoryConfig) BackupCount() int {
+	if c.Backups <= 0 {
+		return 3
+	}
+	return c.Backups
+}
+
+// BackupInterval 返回两次备份的最小间隔
+func (c HistoryConfig) BackupInterval() time.Duration {
+	if c.BackupIntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.BackupIntervalMinutes) * time.Minute
+}
+
+// MaxItemCount 返回最多保留条数
+func (c HistoryConfig) MaxItemCount() int {
+	if c.MaxItems <= 0 {
+		return 1000
+	}
+	return c.MaxItems
+}
+
+// MaxAge 返回条目保留时长，0 表示不限
+func (c HistoryConfig) MaxAge() time.Duration {
+	if c.MaxAgeDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.MaxAgeDays) * 24 * time.Hour
+}
+
+// PruneInterval 返回清理间隔
+func (c HistoryConfig) PruneInterval() time.Duration {
+	if c.PruneIntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.PruneIntervalMinutes) * time.Minute
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPricingCost(t *testing.T) {
+	p := PricingConfig{Models: map[string]ModelPrice{
+		"qwen-plus": {InputPerMillion: 0.8, OutputPerMillion: 2},
+	}}
+	tests := []struct {
+		name               string
+		model              string
+		prompt, completion int
+		want               float64
+	}{
+		{"input and output", "qwen-plus", 1_000_000, 500_000, 1.8},
+		{"small call", "qwen-plus", 1200, 300, 0.00096 + 0.0006},
+		{"no tokens", "qwen-plus", 0, 0, 0},
+		{"unpriced model", "gpt-4o", 1_000_000, 1_000_000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Cost(tt.model, tt.prompt, tt.completion)
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := p.CurrencyCode(); got != "CNY" {
+		t.Errorf("default currency = %q, want CNY", got)
+	}
+	p.Currency = "USD"
+	if got := p.CurrencyCode(); got != "USD" {
+		t.Errorf("currency = %q, want USD", got)
+	}
+}
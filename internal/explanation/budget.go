@@ -0,0 +1,44 @@
+package explanation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gomath/gomath/internal/usage"
+)
+
+// ErrInputTooLong 题目文本或完整 prompt 超出 token 限制
+var ErrInputTooLong = errors.New("input too long")
+
+const (
+	// minCompletionTokens 输出预算下限，低于该值时解析必然被截断，直接拒绝
+	minCompletionTokens = 512
+	// promptOverheadTokens 消息格式等额外开销
+	promptOverheadTokens = 16
+)
+
+// CheckInput 检查题目文本 token 数是否超出 max_input_tokens，调用模型前使用
+func (g *Generator) CheckInput(problemText string) error {
+	n := usage.Estimate(problemText)
+	if limit := g.cfg.MaxInputTokenCount(); n > limit {
+		return fmt.Errorf("%w: problem text is about %d tokens, limit is %d", ErrInputTooLong, n, limit)
+	}
+	return nil
+}
+
+// completionBudget 按上下文窗口计算本次输出 token 上限：不超过 limit，且 prompt + 输出不超出窗口。
+// 剩余空间不足 minCompletionTokens 时返回 ErrInputTooLong。
+func (g *Generator) completionBudget(prompt string, images, limit int) (int, error) {
+	window := g.cfg.ContextWindowTokens()
+	promptTokens := usage.Estimate(prompt) + images*usage.ImageTokensEstimate + promptOverheadTokens
+	avail := window - promptTokens
+	if avail < minCompletionTokens {
+		return 0, fmt.Errorf("%w: prompt is about %d tokens, context window is %d", ErrInputTooLong, promptTokens, window)
+	}
+	if limit > avail {
+		log.Printf("[explanation] max_tokens %d → %d (prompt ≈ %d tokens, context window %d)", limit, avail, promptTokens, window)
+		return avail, nil
+	}
+	return limit, nil
+}
@@ -0,0 +1,78 @@
+package explanation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/usage"
+)
+
+func TestCheckInput(t *testing.T) {
+	g := NewGenerator(config.LLMExplanationConfig{MaxInputTokens: 100})
+	if err := g.CheckInput("解方程 2x + 4 = 10"); err != nil {
+		t.Fatalf("short input: %v", err)
+	}
+	// 粗估与 tiktoken 下都远超 100 token
+	err := g.CheckInput(strings.Repeat("解方程 2x + 4 = 10，", 200))
+	if !errors.Is(err, ErrInputTooLong) {
+		t.Fatalf("long input: err = %v, want ErrInputTooLong", err)
+	}
+	if !strings.Contains(err.Error(), "limit is 100") {
+		t.Errorf("error = %q, want limit in message", err)
+	}
+	// 未配置时默认 2000
+	if err := NewGenerator(config.LLMExplanationConfig{}).CheckInput(strings.Repeat("解", 1000)); err != nil {
+		t.Errorf("default limit: %v", err)
+	}
+}
+
+func TestCompletionBudget(t *testing.T) {
+	// 空 prompt 只计消息格式开销，图片按固定数估算，结果与编码表是否加载无关
+	tests := []struct {
+		name   string
+		window int
+		images int
+		limit  int
+		want   int
+	}{
+		{"limit fits", 4096, 0, 2048, 2048},
+		{"clamped to window", 4096, 0, 8192, 4096 - promptOverheadTokens},
+		{"clamped with images", 4096, 2, 8192, 4096 - promptOverheadTokens - 2*usage.ImageTokensEstimate},
+		{"exactly minimum left", minCompletionTokens + promptOverheadTokens, 0, 8192, minCompletionTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator(config.LLMExplanationConfig{ContextWindow: tt.window})
+			got, err := g.completionBudget("", tt.images, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("budget = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletionBudgetPromptTooLong(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+		prompt string
+		images int
+	}{
+		{"images over window", 4096, "", 4096/usage.ImageTokensEstimate + 1},
+		{"less than minimum left", minCompletionTokens + promptOverheadTokens - 1, "", 0},
+		{"long prompt", 4096, strings.Repeat("解方程 2x + 4 = 10，", 1000), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator(config.LLMExplanationConfig{ContextWindow: tt.window})
+			if _, err := g.completionBudget(tt.prompt, tt.images, 1024); !errors.Is(err, ErrInputTooLong) {
+				t.Errorf("err = %v, want ErrInputTooLong", err)
+			}
+		})
+	}
+}
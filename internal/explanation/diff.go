@@ -0,0 +1,78 @@
+package explanation
+
+// 步骤差异类型
+const (
+	DiffSame    = "same"
+	DiffChanged = "changed"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+)
+
+// StepDiff 两个解析版本之间的一个步骤：Old、New 为步骤在旧、新版本中的下标，不存在时为 -1
+type StepDiff struct {
+	Op   string      `json:"op"`
+	Old  int         `json:"old"`
+	New  int         `json:"new"`
+	From *StepResult `json:"from,omitempty"` // 旧版本中的步骤，added 时为空
+	To   *StepResult `json:"to,omitempty"`   // 新版本中的步骤，removed 时为空
+}
+
+// DiffSteps 按步骤对比两个版本：标题与内容相同（按 NormalizeProblem 规范化后比较）的步骤按最长公共子序列对齐为 same，
+// 两个对齐步骤之间的剩余步骤按顺序两两配对为 changed，多出的为 removed 或 added
+func DiffSteps(prev, next []StepResult) []StepDiff {
+	key := func(st StepResult) string {
+		return NormalizeProblem(st.Title) + "\x00" + NormalizeProblem(st.Content)
+	}
+	a := make([]string, len(prev))
+	for i, st := range prev {
+		a[i] = key(st)
+	}
+	b := make([]string, len(next))
+	for j, st := range next {
+		b[j] = key(st)
+	}
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []StepDiff
+	// gap 输出 prev[i0:i1] 与 next[j0:j1] 之间未对齐的步骤
+	gap := func(i0, i1, j0, j1 int) {
+		for ; i0 < i1 && j0 < j1; i0, j0 = i0+1, j0+1 {
+			out = append(out, StepDiff{Op: DiffChanged, Old: i0, New: j0, From: &prev[i0], To: &next[j0]})
+		}
+		for ; i0 < i1; i0++ {
+			out = append(out, StepDiff{Op: DiffRemoved, Old: i0, New: -1, From: &prev[i0]})
+		}
+		for ; j0 < j1; j0++ {
+			out = append(out, StepDiff{Op: DiffAdded, Old: -1, New: j0, To: &next[j0]})
+		}
+	}
+	i, j, gi, gj := 0, 0, 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			gap(gi, i, gj, j)
+			out = append(out, StepDiff{Op: DiffSame, Old: i, New: j, From: &prev[i], To: &next[j]})
+			i, j = i+1, j+1
+			gi, gj = i, j
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	gap(gi, len(a), gj, len(b))
+	return out
+}
@@ -0,0 +1,36 @@
+package explanation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSteps(t *testing.T) {
+	st := func(title, content string) StepResult { return StepResult{Title: title, Content: content} }
+	prev := []StepResult{
+		st("审题", "已知 $x+1=2$"),
+		st("移项", "$x=2-1$"),
+		st("求解", "$x=1$"),
+		st("检验", "代入成立"),
+	}
+	next := []StepResult{
+		st("审题", "已知  $x + 1 = 2$"), // 仅空白不同
+		st("移项", "两边同时减 1，得 $x=1$"),
+		st("检验", "代入成立"),
+		st("小结", "一元一次方程移项求解"),
+	}
+	var ops []string
+	for _, d := range DiffSteps(prev, next) {
+		ops = append(ops, d.Op)
+	}
+	want := []string{DiffSame, DiffChanged, DiffRemoved, DiffSame, DiffAdded}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops = %v, want %v", ops, want)
+	}
+	if d := DiffSteps(prev, next)[1]; d.Old != 1 || d.New != 1 || d.From.Content != "$x=2-1$" {
+		t.Errorf("changed step = %+v", d)
+	}
+	if d := DiffSteps(nil, next); len(d) != 4 || d[0].Op != DiffAdded || d[0].Old != -1 {
+		t.Errorf("diff from empty = %+v", d)
+	}
+}
@@ -0,0 +1,159 @@
+package explanation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gomath/gomath/internal/usage"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// GradeResult 学生作答批改结果：逐步判定、首个错误步骤、得分与总评
+type GradeResult struct {
+	Steps          []StepVerdict `json:"steps"`
+	FirstErrorStep int           `json:"first_error_step"` // 首个错误步骤序号（从 1 开始），0 表示无错误
+	Score          int           `json:"score"`            // 0~100
+	Feedback       string        `json:"feedback"`
+}
+
+// StepVerdict 学生单步判定
+type StepVerdict struct {
+	Index   int    `json:"index"` // 从 1 开始
+	Content string `json:"content"`
+	Correct bool   `json:"correct"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Grade 将学生作答步骤与参考解析逐步比对，给出每步判定、首个错误步骤、得分与反馈。
+// problemText 可为空（如题目仅有图片），此时仅依据参考解析判断。
+func (g *Generator) Grade(ctx context.Context, problemText string, reference *Result, studentSteps []string) (*GradeResult, error) {
+	if g.cfg.Provider == "" || g.cfg.Model == "" {
+		return nil, fmt.Errorf("llm explanation not configured")
+	}
+	if len(studentSteps) == 0 {
+		return nil, fmt.Errorf("student solution is empty")
+	}
+	if g.cfg.Provider != "openai" {
+		return gradeStub(studentSteps)
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout())
+	defer cancel()
+	release, err := g.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	llm, err := g.newLLM()
+	if err != nil {
+		return nil, err
+	}
+	prompt := buildGradePrompt(problemText, reference, studentSteps)
+	maxTokens, err := g.completionBudget(prompt, 0, g.maxTokens())
+	if err != nil {
+		return nil, err
+	}
+	out, err := llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	}, llms.WithTemperature(0.1), llms.WithMaxTokens(maxTokens))
+	if err != nil {
+		return nil, err
+	}
+	usage.Observe(ctx, g.cfg.Provider, g.cfg.Model, prompt, 0, out)
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("no response from llm")
+	}
+	return parseGradeResponse(out.Choices[0].Content, studentSteps)
+}
+
+func buildGradePrompt(problemText string, reference *Result, studentSteps []string) string {
+	var b strings.Builder
+	b.WriteString("你是一个数学老师，请批改学生的解题过程。\n\n")
+	if problemText != "" {
+		b.WriteString("题目：\n")
+		b.WriteString(problemText)
+		b.WriteString("\n\n")
+	}
+	if reference != nil && len(reference.Steps) > 0 {
+		b.WriteString("参考解析：\n")
+		for i, st := range reference.Steps {
+			b.WriteString(strconv.Itoa(i+1) + ". " + st.Title + "：" + st.Content + "\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("学生作答（每行一步）：\n")
+	for i, st := range studentSteps {
+		b.WriteString(strconv.Itoa(i+1) + ". " + st + "\n")
+	}
+	b.WriteString(`
+请逐步判断学生每一步是否正确（方法可以与参考解析不同，只要推导正确即可），并严格按以下 JSON 对象格式输出（不要其他前后文字）：
+- steps: 数组，与学生作答逐行对应，每项包含 index（从 1 开始）、correct（true/false）、comment（错误原因或简短点评，数学公式用 LaTeX）
+- first_error_step: 第一个错误步骤的 index，全部正确时为 0
+- score: 0 到 100 的整数得分
+- feedback: 给学生的总体反馈，指出错误并给出改进建议
+
+例如：
+{"steps":[{"index":1,"correct":true,"comment":""},{"index":2,"correct":false,"comment":"..."}],"first_error_step":2,"score":60,"feedback":"..."}
+`)
+	return b.String()
+}
+
+func parseGradeResponse(text string, studentSteps []string) (*GradeResult, error) {
+	text = strings.TrimSpace(text)
+	log.Printf("[explanation] grade raw output (len=%d): %s", len(text), text)
+	if text == "" {
+		return nil, fmt.Errorf("parse grade: empty response from model")
+	}
+	text = ExtractJSON(text, "{", "}")
+	var res GradeResult
+	if err := json.Unmarshal([]byte(text), &res); err != nil {
+		return nil, fmt.Errorf("parse grade: %w (response length %d)", err, len(text))
+	}
+	normalizeGrade(&res, studentSteps)
+	return &res, nil
+}
+
+// normalizeGrade 以学生作答为准对齐步骤、回填内容，并根据判定修正首个错误步骤与得分范围
+func normalizeGrade(res *GradeResult, studentSteps []string) {
+	byIndex := make(map[int]StepVerdict, len(res.Steps))
+	for _, v := range res.Steps {
+		byIndex[v.Index] = v
+	}
+	steps := make([]StepVerdict, 0, len(studentSteps))
+	firstErr := 0
+	for i, content := range studentSteps {
+		v, ok := byIndex[i+1]
+		if !ok {
+			// 模型漏判的步骤视为正确，避免误伤
+			v = StepVerdict{Correct: true}
+		}
+		v.Index = i + 1
+		v.Content = content
+		if !v.Correct && firstErr == 0 {
+			firstErr = v.Index
+		}
+		steps = append(steps, v)
+	}
+	res.Steps = steps
+	res.FirstErrorStep = firstErr
+	if res.Score < 0 {
+		res.Score = 0
+	}
+	if res.Score > 100 {
+		res.Score = 100
+	}
+}
+
+// gradeStub 未配置 openai 时的占位：最后一步判错
+func gradeStub(studentSteps []string) (*GradeResult, error) {
+	res := &GradeResult{Score: 60, Feedback: "因式分解正确，但求根时符号出错：由 $x-3=0$ 应得 $x=3$。"}
+	for i := range studentSteps {
+		res.Steps = append(res.Steps, StepVerdict{Index: i + 1, Correct: i < len(studentSteps)-1})
+	}
+	res.Steps[len(res.Steps)-1].Comment = "符号错误"
+	normalizeGrade(res, studentSteps)
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package explanation
+
+import "testing"
+
+func TestNormalizeGrade(t *testing.T) {
+	steps := []string{"(x-2)(x-3)=0", "x-2=0 或 x-3=0", "x=2 或 x=-3"}
+	res := &GradeResult{
+		Score: 130,
+		Steps: []StepVerdict{
+			{Index: 3, Correct: false, Comment: "符号错误"},
+			{Index: 1, Correct: true, Content: "模型改写的内容"},
+			{Index: 9, Correct: false}, // 不存在的步骤
+		},
+	}
+	normalizeGrade(res, steps)
+	if len(res.Steps) != 3 {
+		t.Fatalf("steps = %d, want 3", len(res.Steps))
+	}
+	for i, v := range res.Steps {
+		if v.Index != i+1 || v.Content != steps[i] {
+			t.Errorf("step %d = %+v, want index %d with student content", i, v, i+1)
+		}
+	}
+	if !res.Steps[1].Correct {
+		t.Error("step missed by the model should count as correct")
+	}
+	if res.FirstErrorStep != 3 || res.Steps[2].Comment != "符号错误" {
+		t.Errorf("first error = %d, step 3 = %+v", res.FirstErrorStep, res.Steps[2])
+	}
+	if res.Score != 100 {
+		t.Errorf("score = %d, want clamped to 100", res.Score)
+	}
+
+	res = &GradeResult{Score: -5}
+	normalizeGrade(res, steps)
+	if res.Score != 0 || res.FirstErrorStep != 0 {
+		t.Errorf("score = %d, first error = %d; want 0, 0", res.Score, res.FirstErrorStep)
+	}
+}
+
+func TestParseGradeResponse(t *testing.T) {
+	steps := []string{"2x=6", "x=4"}
+	text := "批改如下：\n```json\n{\"steps\":[{\"index\":1,\"correct\":true},{\"index\":2,\"correct\":false,\"comment\":\"6÷2=3\"}],\"score\":50,\"feedback\":\"除法算错\"}\n```"
+	res, err := parseGradeResponse(text, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Score != 50 || res.Feedback != "除法算错" || res.FirstErrorStep != 2 || res.Steps[1].Content != "x=4" {
+		t.Errorf("parsed = %+v", res)
+	}
+	for _, bad := range []string{"", "  ", "not json"} {
+		if _, err := parseGradeResponse(bad, steps); err == nil {
+			t.Errorf("parseGradeResponse(%q) should fail", bad)
+		}
+	}
+}
@@ -8,13 +8,20 @@ import (
 	"strings"
 
 	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/ratelimit"
+	"github.com/gomath/gomath/internal/usage"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
+// PromptVersion 解析 prompt 版本，修改解析 prompt 时递增，使旧的解析缓存失效
+const PromptVersion = "explain-v2"
+
 // Generator 使用 LLM 题目解析配置块生成分步解析
 type Generator struct {
-	cfg config.LLMExplanationConfig
+	cfg   config.LLMExplanationConfig
+	slots *ratelimit.Concurrency // 可选，上游并发限制
 }
 
 // NewGenerator 根据统一配置中的 llm.explanation 创建
@@ -22,6 +29,11 @@ func NewGenerator(cfg config.LLMExplanationConfig) *Generator {
 	return &Generator{cfg: cfg}
 }
 
+// Fingerprint 解析结果的模型标识（provider、model、prompt 版本），用于缓存键
+func (g *Generator) Fingerprint() string {
+	return g.cfg.Provider + "|" + g.cfg.Model + "|" + PromptVersion
+}
+
 // GenerateFromImage 基于题目图片直接生成分步解析（多模态：图片 + 提示），返回步骤序列
 func (g *Generator) GenerateFromImage(ctx context.Context, imagePath string) (*Result, error) {
 	if g.cfg.Provider == "" || g.cfg.Model == "" {
@@ -32,30 +44,25 @@ func (g *Generator) GenerateFromImage(ctx context.Context, imagePath string) (*R
 	}
 	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout())
 	defer cancel()
-	data, mime, err := readImageAsBase64(imagePath)
+	release, err := g.acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("read image: %w", err)
-	}
-	opts := []openai.Option{
-		openai.WithToken(g.cfg.APIKey()),
-		openai.WithModel(g.cfg.Model),
+		return nil, err
 	}
-	if g.cfg.APIBase != "" {
-		opts = append(opts, openai.WithBaseURL(strings.TrimSuffix(g.cfg.APIBase, "/")))
+	defer release()
+	data, mime, err := media.ReadBase64(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
 	}
-	llm, err := openai.New(opts...)
+	llm, err := g.newLLM()
 	if err != nil {
 		return nil, err
 	}
-	temperature := g.cfg.Temperature
-	if temperature <= 0 {
-		temperature = 0.3
-	}
-	maxTokens := g.cfg.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = 4096
+	temperature := g.temperature()
+	prompt := withStyle(ctx, buildPromptFromImage())
+	maxTokens, err := g.completionBudget(prompt, 1, g.maxTokens())
+	if err != nil {
+		return nil, err
 	}
-	prompt := buildPromptFromImage()
 	dataURL := "data:" + mime + ";base64," + data
 	content := llms.MessageContent{
 		Role: llms.ChatMessageTypeHuman,
@@ -69,6 +76,7 @@ func (g *Generator) GenerateFromImage(ctx context.Context, imagePath string) (*R
 	if err != nil {
 		return nil, err
 	}
+	usage.Observe(ctx, g.cfg.Provider, g.cfg.Model, prompt, 1, out)
 	if len(out.Choices) == 0 {
 		return nil, fmt.Errorf("no response from llm")
 	}
@@ -76,13 +84,16 @@ func (g *Generator) GenerateFromImage(ctx context.Context, imagePath string) (*R
 }
 
 func buildPromptFromImage() string {
-	return `你是一个数学题解析助手。请根据图片中的数学题目，直接给出分步解析，并严格按以下 JSON 数组格式输出（不要其他前后文字），每步包含 title、content、image_prompt：
-- title: 该步简短标题
-- content: 该步详细解析，数学公式用 LaTeX，行内用 $...$，块级用 $$...$$
-- image_prompt: 用于生成该步讲解图的英文描述（示意图、几何、函数图等）
+	return `你是一个数学题解析助手。请根据图片中的数学题目，直接给出分步解析，并严格按以下 JSON 对象格式输出（不要其他前后文字）：
+- problem: 图片中题目的完整文字，数学公式用 LaTeX（行内 $...$）
+- steps: 步骤数组，每步包含 title、content、image_prompt：
+  - title: 该步简短标题
+  - content: 该步详细解析，数学公式用 LaTeX，行内用 $...$，块级用 $$...$$
+  - image_prompt: 用于生成该步讲解图的英文描述（示意图、几何、函数图等）
+- answer: 最终答案，简洁表述，公式用 LaTeX
 
-直接输出 JSON 数组，例如：
-[{"title":"步骤1","content":"...","image_prompt":"..."},{"title":"步骤2",...}]
+直接输出 JSON 对象，例如：
+{"problem":"...","steps":[{"title":"步骤1","content":"...","image_prompt":"..."},{"title":"步骤2",...}],"answer":"..."}
 `
 }
 
@@ -91,65 +102,103 @@ func (g *Generator) Generate(ctx context.Context, problemText string) (*Result,
 	if g.cfg.Provider == "" || g.cfg.Model == "" {
 		return nil, fmt.Errorf("llm explanation not configured")
 	}
+	if err := g.CheckInput(problemText); err != nil {
+		return nil, err
+	}
 	// 使用 langchaingo 调用大模型；此处以 OpenAI 为例，其他 provider 可扩展
 	if g.cfg.Provider != "openai" {
 		return generateStub(problemText)
 	}
 	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout())
 	defer cancel()
-	opts := []openai.Option{
-		openai.WithToken(g.cfg.APIKey()),
-		openai.WithModel(g.cfg.Model),
-	}
-	if g.cfg.APIBase != "" {
-		opts = append(opts, openai.WithBaseURL(g.cfg.APIBase))
-	}
-	llm, err := openai.New(opts...)
+	release, err := g.acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	temperature := g.cfg.Temperature
-	if temperature <= 0 {
-		temperature = 0.3
+	defer release()
+	llm, err := g.newLLM()
+	if err != nil {
+		return nil, err
 	}
-	maxTokens := g.cfg.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = 4096
+	temperature := g.temperature()
+	prompt := withStyle(ctx, buildPrompt(problemText))
+	maxTokens, err := g.completionBudget(prompt, 0, g.maxTokens())
+	if err != nil {
+		return nil, err
 	}
-	prompt := buildPrompt(problemText)
 	out, err := llm.GenerateContent(ctx, []llms.MessageContent{
 		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
 	}, llms.WithTemperature(temperature), llms.WithMaxTokens(maxTokens))
 	if err != nil {
 		return nil, err
 	}
+	usage.Observe(ctx, g.cfg.Provider, g.cfg.Model, prompt, 0, out)
 	if len(out.Choices) == 0 {
 		return nil, fmt.Errorf("no response from llm")
 	}
-	text := out.Choices[0].Content
-	return parseStepsResponse(text)
+	res, err := parseStepsResponse(out.Choices[0].Content)
+	if err != nil {
+		return nil, err
+	}
+	res.Problem = problemText
+	return res, nil
 }
 
 func buildPrompt(problemText string) string {
-	return `你是一个数学题解析助手。请对以下题目给出分步解析，并严格按以下 JSON 数组格式输出（不要其他前后文字），每步包含 title、content、image_prompt：
-- title: 该步简短标题
-- content: 该步详细解析，数学公式用 LaTeX，行内用 $...$，块级用 $$...$$
-- image_prompt: 用于生成该步讲解图的英文描述（示意图、几何、函数图等）
+	return `你是一个数学题解析助手。请对以下题目给出分步解析，并严格按以下 JSON 对象格式输出（不要其他前后文字）：
+- steps: 步骤数组，每步包含 title、content、image_prompt：
+  - title: 该步简短标题
+  - content: 该步详细解析，数学公式用 LaTeX，行内用 $...$，块级用 $$...$$
+  - image_prompt: 用于生成该步讲解图的英文描述（示意图、几何、函数图等）
+- answer: 最终答案，简洁表述，公式用 LaTeX
 
 题目：
 ` + problemText + `
 
-直接输出 JSON 数组，例如：
-[{"title":"步骤1","content":"...","image_prompt":"..."},{"title":"步骤2",...}]
+直接输出 JSON 对象，例如：
+{"steps":[{"title":"步骤1","content":"...","image_prompt":"..."},{"title":"步骤2",...}],"answer":"..."}
 `
 }
 
+// stepsResponse 解析 prompt 要求的输出格式
+type stepsResponse struct {
+	Problem string `json:"problem"`
+	Steps   []Step `json:"steps"`
+	Answer  string `json:"answer"`
+}
+
 func parseStepsResponse(text string) (*Result, error) {
 	text = strings.TrimSpace(text)
 	log.Printf("[explanation] llm raw output (len=%d): %s", len(text), text)
 	if text == "" {
 		return nil, fmt.Errorf("parse llm steps: empty response from model")
 	}
+	// 兼容只输出步骤数组（旧版 prompt）的模型
+	var out stepsResponse
+	if obj, arr := strings.Index(text, "{"), strings.Index(text, "["); obj >= 0 && (arr < 0 || obj < arr) {
+		text = ExtractJSON(text, "{", "}")
+		if err := json.Unmarshal([]byte(text), &out); err != nil {
+			return nil, fmt.Errorf("parse llm steps: %w (response length %d)", err, len(text))
+		}
+	} else {
+		text = ExtractJSON(text, "[", "]")
+		if err := json.Unmarshal([]byte(text), &out.Steps); err != nil {
+			return nil, fmt.Errorf("parse llm steps: %w (response length %d)", err, len(text))
+		}
+	}
+	res := &Result{Problem: out.Problem, Answer: out.Answer, Steps: make([]StepResult, 0, len(out.Steps))}
+	for _, s := range out.Steps {
+		res.Steps = append(res.Steps, StepResult{
+			Title:       s.Title,
+			Content:     s.Content,
+			ImagePrompt: s.ImagePrompt,
+		})
+	}
+	return res, nil
+}
+
+// ExtractJSON 去除 markdown 代码块，并只保留第一个 open 到最后一个 close 之间的 JSON（去掉模型附带的前后说明文字）
+func ExtractJSON(text, open, close string) string {
 	// 去除可能的 markdown 代码块（```json ... ``` 或 ``` ... ```）
 	if strings.HasPrefix(text, "```") {
 		text = strings.TrimPrefix(text, "```json")
@@ -157,31 +206,60 @@ func parseStepsResponse(text string) (*Result, error) {
 		text = strings.TrimSuffix(text, "```")
 		text = strings.TrimSpace(text)
 	}
-	// 若仍有前后说明文字，尝试只取第一个 '[' 到最后一个 ']' 的 JSON 数组
-	if idx := strings.Index(text, "["); idx >= 0 {
-		if last := strings.LastIndex(text, "]"); last > idx {
-			text = text[idx : last+1]
+	if idx := strings.Index(text, open); idx >= 0 {
+		if last := strings.LastIndex(text, close); last > idx {
+			text = text[idx : last+len(close)]
 		}
 	}
-	var steps []Step
-	if err := json.Unmarshal([]byte(text), &steps); err != nil {
-		return nil, fmt.Errorf("parse llm steps: %w (response length %d)", err, len(text))
+	return text
+}
+
+// SetConcurrency 设置上游并发限制（与识图共用同一上游时传入同一个实例）
+func (g *Generator) SetConcurrency(c *ratelimit.Concurrency) {
+	g.slots = c
+}
+
+// acquire 占用一个上游调用名额，排队时间计入本次请求超时
+func (g *Generator) acquire(ctx context.Context) (func(), error) {
+	return g.slots.Acquire(ctx, g.cfg.Provider+"|"+g.cfg.APIBase)
+}
+
+// newLLM 按配置创建 OpenAI 兼容客户端
+func (g *Generator) newLLM() (*openai.LLM, error) {
+	opts := []openai.Option{
+		openai.WithToken(g.cfg.APIKey()),
+		openai.WithModel(g.cfg.Model),
 	}
-	res := &Result{Steps: make([]StepResult, 0, len(steps))}
-	for _, s := range steps {
-		res.Steps = append(res.Steps, StepResult{
-			Title:       s.Title,
-			Content:     s.Content,
-			ImagePrompt: s.ImagePrompt,
-		})
+	if g.cfg.APIBase != "" {
+		opts = append(opts, openai.WithBaseURL(strings.TrimSuffix(g.cfg.APIBase, "/")))
 	}
-	return res, nil
+	return openai.New(opts...)
+}
+
+// temperature 返回配置的温度，≤0 时默认 0.3
+func (g *Generator) temperature() float64 {
+	if g.cfg.Temperature <= 0 {
+		return 0.3
+	}
+	return g.cfg.Temperature
+}
+
+// maxTokens 返回配置的最大输出 token 数，≤0 时默认 4096
+func (g *Generator) maxTokens() int {
+	if g.cfg.MaxTokens <= 0 {
+		return 4096
+	}
+	return g.cfg.MaxTokens
 }
 
 // generateStub 未配置 openai 时的占位
 func generateStub(problemText string) (*Result, error) {
-	_ = problemText
+	if problemText == "" {
+		problemText = "解方程 $x^2 - 5x + 6 = 0$。"
+	}
 	return &Result{
+		Problem: problemText,
+		Answer:  "$x=2$ 或 $x=3$",
 		Steps: []StepResult{
 			{Title: "步骤1", Content: "设 $x^2 - 5x + 6 = (x-a)(x-b)$，则 $a+b=5$，$ab=6$。", ImagePrompt: "quadratic equation factored form"},
 			{Title: "步骤2", Content: "解得 $a=2,b=3$ 或 $a=3,b=2$，故 $x=2$ 或 $x=3$。", ImagePrompt: "number line with roots"},
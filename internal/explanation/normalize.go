@@ -0,0 +1,53 @@
+package explanation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gomath/gomath/internal/mathcheck"
+)
+
+var (
+	// \left( \right) 等定界符修饰不影响题意
+	reLeftRight = regexp.MustCompile(`\\(left|right)([^a-zA-Z]|$)`)
+	// \dfrac \tfrac 统一为 \frac
+	reFrac = regexp.MustCompile(`\\[dt]frac([^a-zA-Z]|$)`)
+	// 排版用空白命令：\, \; \: \! \quad \qquad \displaystyle
+	reSpacing = regexp.MustCompile(`\\[,;:!]|\\q?quad([^a-zA-Z]|$)|\\displaystyle([^a-zA-Z]|$)`)
+)
+
+// 公式定界符统一为 $
+var delimReplacer = strings.NewReplacer(`$$`, `$`, `\(`, `$`, `\)`, `$`, `\[`, `$`, `\]`, `$`)
+
+// NormalizeProblem 规范化题目文本，用作解析缓存键：全角转半角、统一 LaTeX 写法并去除不影响题意的空白。
+// 仅在两侧均为字母或数字时保留一个空格（如 "\cdot x"、"2 3"），其余空白全部去掉。
+func NormalizeProblem(text string) string {
+	text = mathcheck.ToHalfWidth(text)
+	text = reLeftRight.ReplaceAllString(text, "$2")
+	text = reFrac.ReplaceAllString(text, `\frac$1`)
+	text = reSpacing.ReplaceAllString(text, " $1$2")
+	text = delimReplacer.Replace(text)
+
+	runes := []rune(strings.TrimSpace(text))
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if !unicode.IsSpace(runes[i]) {
+			b.WriteRune(runes[i])
+			continue
+		}
+		j := i
+		for j < len(runes) && unicode.IsSpace(runes[j]) {
+			j++
+		}
+		if i > 0 && j < len(runes) && isASCIIAlnum(runes[i-1]) && isASCIIAlnum(runes[j]) {
+			b.WriteByte(' ')
+		}
+		i = j - 1
+	}
+	return b.String()
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
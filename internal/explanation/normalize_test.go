@@ -0,0 +1,28 @@
+package explanation
+
+import "testing"
+
+func TestNormalizeProblem(t *testing.T) {
+	same := [][2]string{
+		{"求方程 $x^2 - 5x + 6 = 0$ 的解。", "求方程$x^2-5x+6=0$的解。"},
+		{"求方程　$ｘ^2－5x＋6＝0$ 的解", "求方程 $x^2-5x+6=0$ 的解"},
+		{`$$\dfrac{1}{2}\left( x+1 \right)$$`, `$\frac{1}{2}(x+1)$`},
+		{`$a\,b \cdot  x$`, `$a b \cdot x$`},
+		{"solve  for\n x", "solve for x"},
+	}
+	for _, c := range same {
+		if a, b := NormalizeProblem(c[0]), NormalizeProblem(c[1]); a != b {
+			t.Errorf("NormalizeProblem(%q) = %q, NormalizeProblem(%q) = %q; want equal", c[0], a, c[1], b)
+		}
+	}
+	different := [][2]string{
+		{"$2 3$", "$23$"},
+		{`$\cdot x$`, `$\cdotx$`},
+		{`$\leftarrow$`, `$arrow$`},
+	}
+	for _, c := range different {
+		if NormalizeProblem(c[0]) == NormalizeProblem(c[1]) {
+			t.Errorf("NormalizeProblem(%q) == NormalizeProblem(%q), want different", c[0], c[1])
+		}
+	}
+}
@@ -40,3 +40,10 @@ func (s *Store) Update(id string, r *Result) {
 	defer s.mu.Unlock()
 	s.byID[id] = r
 }
+
+// Delete 删除解析结果
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, id)
+}
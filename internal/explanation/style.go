@@ -0,0 +1,50 @@
+package explanation
+
+import "context"
+
+// 解析风格：同一题可按不同风格重新生成，各风格的结果分别缓存，版本的模型标识中带上风格；空为默认风格
+const (
+	StyleConcise  = "concise"  // 简洁：只保留关键步骤
+	StyleDetailed = "detailed" // 详细：每步写出依据，适合基础薄弱的学生
+)
+
+// styleInstructions 各风格附加在 prompt 末尾的要求
+var styleInstructions = map[string]string{
+	StyleConcise:  "解析风格：简洁。只保留关键步骤（一般不超过 4 步），每步一两句话，省略显然的计算过程。",
+	StyleDetailed: "解析风格：详细。面向基础薄弱的学生，每步写出所用的概念、公式或定理，并说明为什么这样做，计算过程不省略。",
+}
+
+// ValidStyle 判断解析风格是否受支持，空为默认风格
+func ValidStyle(style string) bool {
+	_, ok := styleInstructions[style]
+	return ok || style == ""
+}
+
+type styleKey struct{}
+
+// WithStyle 返回指定解析风格的 context，Generate 与 GenerateFromImage 按该风格生成
+func WithStyle(ctx context.Context, style string) context.Context {
+	return context.WithValue(ctx, styleKey{}, style)
+}
+
+// StyleFrom 返回 ctx 上的解析风格，未指定时为空
+func StyleFrom(ctx context.Context) string {
+	style, _ := ctx.Value(styleKey{}).(string)
+	return style
+}
+
+// StyledFingerprint 在模型标识后附加解析风格，默认风格不变
+func StyledFingerprint(fp, style string) string {
+	if style == "" {
+		return fp
+	}
+	return fp + "+" + style
+}
+
+// withStyle 按 ctx 上的解析风格在 prompt 末尾附加要求
+func withStyle(ctx context.Context, prompt string) string {
+	if instr := styleInstructions[StyleFrom(ctx)]; instr != "" {
+		return prompt + "\n" + instr + "\n"
+	}
+	return prompt
+}
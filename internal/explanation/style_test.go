@@ -0,0 +1,29 @@
+package explanation
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStyle(t *testing.T) {
+	for style, want := range map[string]bool{"": true, StyleConcise: true, StyleDetailed: true, "funny": false} {
+		if got := ValidStyle(style); got != want {
+			t.Errorf("ValidStyle(%q) = %v, want %v", style, got, want)
+		}
+	}
+	prompt := buildPrompt("2x + 4 = 10")
+	if got := withStyle(context.Background(), prompt); got != prompt {
+		t.Error("default style changed the prompt")
+	}
+	ctx := WithStyle(context.Background(), StyleDetailed)
+	if got := withStyle(ctx, prompt); !strings.HasPrefix(got, prompt) || !strings.Contains(got, styleInstructions[StyleDetailed]) {
+		t.Errorf("detailed prompt = %q", got)
+	}
+	if got := StyledFingerprint("openai|m|"+PromptVersion, ""); got != "openai|m|"+PromptVersion {
+		t.Errorf("default fingerprint = %q", got)
+	}
+	if got := StyledFingerprint("openai|m|"+PromptVersion, StyleConcise); got != "openai|m|"+PromptVersion+"+concise" {
+		t.Errorf("concise fingerprint = %q", got)
+	}
+}
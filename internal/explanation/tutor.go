@@ -0,0 +1,111 @@
+package explanation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gomath/gomath/internal/usage"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// TutorReply 苏格拉底式辅导的单轮回应
+type TutorReply struct {
+	Valid bool   `json:"valid"` // 学生这一步是否正确
+	Done  bool   `json:"done"`  // 学生是否已得出最终答案
+	Reply string `json:"reply"` // 正确时给予鼓励并引导下一步，错误时提出针对性问题，不直接给出答案
+}
+
+// Tutor 判断学生提交的下一步是否正确，并给出苏格拉底式回应。
+// accepted 为此前已通过的步骤；hint 为本地检查给出的补充信息（可为空），供模型参考。
+func (g *Generator) Tutor(ctx context.Context, problemText string, accepted []string, step, hint string) (*TutorReply, error) {
+	if g.cfg.Provider == "" || g.cfg.Model == "" {
+		return nil, fmt.Errorf("llm explanation not configured")
+	}
+	if g.cfg.Provider != "openai" {
+		return tutorStub(step)
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout())
+	defer cancel()
+	release, err := g.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	llm, err := g.newLLM()
+	if err != nil {
+		return nil, err
+	}
+	prompt := buildTutorPrompt(problemText, accepted, step, hint)
+	maxTokens, err := g.completionBudget(prompt, 0, 1024)
+	if err != nil {
+		return nil, err
+	}
+	out, err := llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	}, llms.WithTemperature(g.temperature()), llms.WithMaxTokens(maxTokens))
+	if err != nil {
+		return nil, err
+	}
+	usage.Observe(ctx, g.cfg.Provider, g.cfg.Model, prompt, 0, out)
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("no response from llm")
+	}
+	return parseTutorResponse(out.Choices[0].Content)
+}
+
+func buildTutorPrompt(problemText string, accepted []string, step, hint string) string {
+	var b strings.Builder
+	b.WriteString("你是一位采用苏格拉底式教学的数学老师，正在一步一步地引导学生独立解题。\n\n题目：\n")
+	b.WriteString(problemText)
+	b.WriteString("\n\n")
+	if len(accepted) > 0 {
+		b.WriteString("学生已完成的步骤：\n")
+		for i, st := range accepted {
+			b.WriteString(strconv.Itoa(i+1) + ". " + st + "\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("学生提交的下一步：\n")
+	b.WriteString(step)
+	b.WriteString("\n\n")
+	if hint != "" {
+		b.WriteString("自动检查提示（供参考）：" + hint + "\n\n")
+	}
+	b.WriteString(`请判断这一步是否正确且是合理的推进，并严格按以下 JSON 对象格式输出（不要其他前后文字）：
+- valid: 这一步是否正确（true/false）
+- done: 学生是否已经得出题目的最终答案（true/false）
+- reply: 对学生说的话。正确时给予简短鼓励，并用一个问题引导其思考下一步；错误时不要指出正确答案，而是提出一个有针对性的问题，帮助学生自己发现错误。数学公式用 LaTeX（行内 $...$）。
+
+例如：
+{"valid":false,"done":false,"reply":"..."}
+`)
+	return b.String()
+}
+
+func parseTutorResponse(text string) (*TutorReply, error) {
+	text = strings.TrimSpace(text)
+	log.Printf("[explanation] tutor raw output (len=%d): %s", len(text), text)
+	if text == "" {
+		return nil, fmt.Errorf("parse tutor reply: empty response from model")
+	}
+	text = ExtractJSON(text, "{", "}")
+	var reply TutorReply
+	if err := json.Unmarshal([]byte(text), &reply); err != nil {
+		return nil, fmt.Errorf("parse tutor reply: %w (response length %d)", err, len(text))
+	}
+	return &reply, nil
+}
+
+// tutorStub 未配置 openai 时的占位：总是通过，出现「x =」视为得出答案
+func tutorStub(step string) (*TutorReply, error) {
+	done := strings.Contains(strings.ReplaceAll(step, " ", ""), "x=")
+	reply := "很好！想一想，接下来可以怎样把式子变得更简单？"
+	if done {
+		reply = "太棒了，你已经得出答案。能把结果代回原式检验一下吗？"
+	}
+	return &TutorReply{Valid: true, Done: done, Reply: reply}, nil
+}
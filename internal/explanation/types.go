@@ -1,21 +1,35 @@
 package explanation
 
+import "github.com/gomath/gomath/internal/usage"
+
 // Step 解析步骤：标题、正文（Markdown+LaTeX）、配图描述
 type Step struct {
-	Title      string `json:"title"`
-	Content    string `json:"content"`     // Markdown，公式用 $...$ / $$...$$
+	Title       string `json:"title"`
+	Content     string `json:"content"`      // Markdown，公式用 $...$ / $$...$$
 	ImagePrompt string `json:"image_prompt"` // 用于生成该步讲解图的描述
 }
 
 // Result 分步解析结果，与步骤一一对应的配图在生成后填入 ImageURL
 type Result struct {
-	Steps []StepResult `json:"steps"`
+	Problem   string         `json:"problem,omitempty"`    // 题目文字：文本解析为原题，看图解析为模型转写
+	ImagePath string         `json:"image_path,omitempty"` // 看图解析的题目图片（相对 upload 目录）
+	Steps     []StepResult   `json:"steps"`
+	Answer    string         `json:"answer,omitempty"`  // 最终答案（Markdown+LaTeX）
+	Usage     *usage.Summary `json:"usage,omitempty"`   // 生成该结果的模型用量与费用，命中缓存时为空
+	UserID    string         `json:"user_id,omitempty"` // 发起解析的用户，未开启鉴权时为空
 }
 
 // StepResult 单步展示：文字 + 配图 URL（可选）
 type StepResult struct {
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	ImageURL   string `json:"image_url,omitempty"`   // 讲解图 URL，空表示暂无图
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	ImageURL    string `json:"image_url,omitempty"` // 讲解图 URL，空表示暂无图
 	ImagePrompt string `json:"image_prompt,omitempty"`
 }
+
+// Clone 深拷贝，供多个请求共享同一次生成结果时各自保存与修改
+func (r *Result) Clone() *Result {
+	cp := *r
+	cp.Steps = append(make([]StepResult, 0, len(r.Steps)), r.Steps...)
+	return &cp
+}
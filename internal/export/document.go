@@ -0,0 +1,159 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Document 待导出的一道题的解析：题目、分步解析与最终答案。正文均为 Markdown + LaTeX（$...$ / $$...$$）
+type Document struct {
+	Title        string
+	Problem      string
+	ProblemImage string // 题目图片：http(s) 链接或 data URI，空表示无
+	Steps        []Step
+	Answer       string
+}
+
+// Step 单步：标题、正文与配图（http(s) 链接或 data URI）
+type Step struct {
+	Title   string
+	Content string
+	Image   string
+}
+
+// segment 正文片段：普通文字或公式（Raw 保留原始定界符，Body 为定界符内的公式）
+type segment struct {
+	Math    bool
+	Display bool
+	Raw     string
+	Body    string
+}
+
+// splitMath 将正文切分为文字与公式片段。支持 $$...$$、$...$、\[...\]、\(...\)，
+// \$ 视为普通美元符号，未闭合的定界符按普通文字处理
+func splitMath(s string) []segment {
+	var out []segment
+	text := 0
+	flush := func(end int) {
+		if end > text {
+			out = append(out, segment{Raw: s[text:end]})
+		}
+	}
+	for i := 0; i < len(s); {
+		open, close, display := "", "", false
+		switch {
+		case strings.HasPrefix(s[i:], `\$`):
+			i += 2
+			continue
+		case strings.HasPrefix(s[i:], "$$"):
+			open, close, display = "$$", "$$", true
+		case s[i] == '$':
+			open, close = "$", "$"
+		case strings.HasPrefix(s[i:], `\[`):
+			open, close, display = `\[`, `\]`, true
+		case strings.HasPrefix(s[i:], `\(`):
+			open, close = `\(`, `\)`
+		default:
+			i++
+			continue
+		}
+		end := indexClose(s[i+len(open):], close)
+		if end <= 0 {
+			i += len(open)
+			continue
+		}
+		flush(i)
+		body := s[i+len(open) : i+len(open)+end]
+		next := i + len(open) + end + len(close)
+		out = append(out, segment{Math: true, Display: display, Raw: s[i:next], Body: body})
+		i, text = next, next
+	}
+	flush(len(s))
+	return out
+}
+
+// indexClose 查找未转义的闭合定界符
+func indexClose(s, close string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && close == "$" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], close) {
+			return i
+		}
+	}
+	return -1
+}
+
+// textFormat 正文转换为目标格式的规则
+type textFormat struct {
+	escape              func(string) string  // 转义普通文字
+	math                func(segment) string // 输出公式
+	boldOpen, boldClose string
+	bullet              string // 行首 - / * 列表项的前缀
+	newline             string
+}
+
+// renderText 按 textFormat 转换 Markdown + LaTeX 正文：公式交给 math，文字转义后支持 **粗体**、
+// 行首 # 标题（去掉 #）与 -/* 列表，\$ 还原为普通美元符号
+func renderText(s string, f textFormat) string {
+	var b strings.Builder
+	lineStart, bold := true, false
+	closeBold := func() {
+		if bold {
+			b.WriteString(f.boldClose)
+			bold = false
+		}
+	}
+	for _, seg := range splitMath(s) {
+		if seg.Math {
+			b.WriteString(f.math(seg))
+			lineStart = false
+			continue
+		}
+		for _, line := range strings.SplitAfter(seg.Raw, "\n") {
+			text, newline := strings.CutSuffix(line, "\n")
+			if lineStart {
+				trimmed := strings.TrimLeft(text, " \t")
+				switch {
+				case strings.HasPrefix(trimmed, "#"):
+					text = strings.TrimLeft(trimmed, "# ")
+				case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "):
+					b.WriteString(f.bullet)
+					text = trimmed[2:]
+				}
+			}
+			for i, p := range strings.Split(text, "**") {
+				if i > 0 {
+					if bold {
+						closeBold()
+					} else {
+						b.WriteString(f.boldOpen)
+						bold = true
+					}
+				}
+				b.WriteString(f.escape(strings.ReplaceAll(p, `\$`, "$")))
+			}
+			if text != "" {
+				lineStart = false
+			}
+			if newline {
+				// Markdown 粗体不跨行，行尾补齐
+				closeBold()
+				b.WriteString(f.newline)
+				lineStart = true
+			}
+		}
+	}
+	closeBold()
+	return b.String()
+}
+
+// stepTitle 步骤标题，为空时用序号
+func stepTitle(i int, st Step) string {
+	if strings.TrimSpace(st.Title) == "" {
+		return "步骤 " + strconv.Itoa(i+1)
+	}
+	return st.Title
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMath(t *testing.T) {
+	segs := splitMath(`价格 \$5，设 $x_1$ 满足 $$x^2=1$$ 与 \(y\)，未闭合 $z`)
+	var got []string
+	for _, s := range segs {
+		if s.Math {
+			got = append(got, "M:"+s.Body)
+		} else {
+			got = append(got, "T:"+s.Raw)
+		}
+	}
+	want := []string{`T:价格 \$5，设 `, "M:x_1", "T: 满足 ", "M:x^2=1", "T: 与 ", "M:y", "T:，未闭合 $z"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestLaTeXText(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`50% 的 a_b & #1 \$3 {x}`, `50\% 的 a\_b \& \#1 \$3 \{x\}`},
+		{`**注意** $a_1 + b$`, `\textbf{注意} $a_1 + b$`},
+		{"## 小结\n- 因式 **分解", "小结\n\n\\textbullet{} 因式 \\textbf{分解}"},
+		{"$$\\frac{1}{2}$$", `\[\frac{1}{2}\]`},
+	}
+	for _, c := range cases {
+		if got := latexText(c.in); got != c.want {
+			t.Errorf("latexText(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := &Document{
+		Title:        "解方程",
+		Problem:      "解方程 $x^2-5x+6=0$",
+		ProblemImage: "data:image/png;base64,AAAA",
+		Steps: []Step{
+			{Title: "因式分解", Content: "<script>alert(1)</script> $(x-2)(x-3)=0$", Image: "https://img.example/1.png?a=1&b=%20#f"},
+			{Content: "故 $x=2$ 或 $x=3$"},
+		},
+		Answer: "$x=2$ 或 $x=3$",
+	}
+
+	md := string(Markdown(d))
+	for _, s := range []string{"# 解方程", "![题目](data:image/png;base64,AAAA)", "### 步骤 2", "## 答案\n\n$x=2$ 或 $x=3$"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("markdown missing %q", s)
+		}
+	}
+
+	tex := string(LaTeX(d))
+	for _, s := range []string{`\documentclass[11pt]{article}`, `\subsection*{因式分解}`, `$(x-2)(x-3)=0$`, `\href{https://img.example/1.png?a=1&b=\%20\#f}`, `\end{document}`} {
+		if !strings.Contains(tex, s) {
+			t.Errorf("latex missing %q", s)
+		}
+	}
+	if strings.Contains(tex, "data:image") {
+		t.Error("latex should omit data URI images")
+	}
+
+	h := string(HTML(d))
+	if strings.Contains(h, "<script>alert") {
+		t.Error("html content not escaped")
+	}
+	for _, s := range []string{`<img src="data:image/png;base64,AAAA"`, `1.png?a=1&amp;b=%20#f`, "$(x-2)(x-3)=0$", "@media print"} {
+		if !strings.Contains(h, s) {
+			t.Errorf("html missing %q", s)
+		}
+	}
+}
+
+func TestWorksheet(t *testing.T) {
+	problems := func() []Document {
+		var out []Document
+		for _, s := range []string{"甲", "乙", "丙", "丁", "戊"} {
+			out = append(out, Document{Problem: "题" + s, Steps: []Step{{Title: "解" + s}}, Answer: "答" + s})
+		}
+		return out
+	}
+	order := func(w *Worksheet) (out []string) {
+		for _, p := range w.Problems {
+			out = append(out, p.Problem)
+		}
+		return out
+	}
+	ws := &Worksheet{Title: "练习", Problems: problems(), Numbering: true, SpaceCM: 5}
+	key := &Worksheet{Title: "练习（答案）", Problems: problems(), Answers: true}
+	ws.Shuffle(42)
+	key.Shuffle(42)
+	if a, b := order(ws), order(key); strings.Join(a, "") != strings.Join(b, "") {
+		t.Errorf("same seed, different order: %v vs %v", a, b)
+	}
+
+	h := string(WorksheetHTML(ws))
+	if !strings.Contains(h, `<span class="no">5.</span>`) || !strings.Contains(h, "height:5cm") {
+		t.Error("worksheet missing numbering or work space")
+	}
+	if strings.Contains(h, "解甲") || strings.Contains(h, "答甲") {
+		t.Error("worksheet should not contain solutions")
+	}
+	h = string(WorksheetHTML(key))
+	if !strings.Contains(h, "<h3>解甲</h3>") || !strings.Contains(h, "答案：答甲") || strings.Contains(h, `class="no"`) {
+		t.Error("answer key missing solutions or has unexpected numbering")
+	}
+}
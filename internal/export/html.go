@@ -0,0 +1,92 @@
+package export
+
+import (
+	"html"
+	"strings"
+)
+
+// KaTeX 版本与前端一致，导出的 HTML 通过 CDN 渲染公式，离线时公式以 LaTeX 源码显示
+const katexCDN = "https://cdn.jsdelivr.net/npm/katex@0.16.28/dist"
+
+// HTML 渲染为单个 HTML 文件：样式内联、图片为链接或内嵌 data URI，公式由 KaTeX 在浏览器端渲染，适合打印
+func HTML(d *Document) []byte {
+	var b strings.Builder
+	title := html.EscapeString(oneLine(d.Title))
+	b.WriteString(htmlHead(title, ""))
+	b.WriteString("<h1>" + title + "</h1>\n")
+	b.WriteString("<section>\n<h2>题目</h2>\n")
+	if strings.TrimSpace(d.Problem) != "" {
+		b.WriteString(`<div class="content">` + htmlText(strings.TrimSpace(d.Problem)) + "</div>\n")
+	}
+	if d.ProblemImage != "" {
+		b.WriteString(`<img src="` + html.EscapeString(d.ProblemImage) + `" alt="题目">` + "\n")
+	}
+	b.WriteString("</section>\n<section>\n<h2>解析</h2>\n")
+	for i, st := range d.Steps {
+		b.WriteString(`<div class="step">` + "\n<h3>" + htmlText(oneLine(stepTitle(i, st))) + "</h3>\n")
+		if c := strings.TrimSpace(st.Content); c != "" {
+			b.WriteString(`<div class="content">` + htmlText(c) + "</div>\n")
+		}
+		if st.Image != "" {
+			b.WriteString(`<img src="` + html.EscapeString(st.Image) + `" alt="` + html.EscapeString(oneLine(stepTitle(i, st))) + `">` + "\n")
+		}
+		b.WriteString("</div>\n")
+	}
+	b.WriteString("</section>\n")
+	if strings.TrimSpace(d.Answer) != "" {
+		b.WriteString("<section>\n<h2>答案</h2>\n" + `<div class="content answer">` + htmlText(strings.TrimSpace(d.Answer)) + "</div>\n</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+// htmlHead 文档头：KaTeX 自动渲染 + 屏幕与打印样式，extraCSS 追加在通用样式之后
+func htmlHead(title, extraCSS string) string {
+	return `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>` + title + `</title>
+<link rel="stylesheet" href="` + katexCDN + `/katex.min.css">
+<script defer src="` + katexCDN + `/katex.min.js"></script>
+<script defer src="` + katexCDN + `/contrib/auto-render.min.js" onload="renderMathInElement(document.body,{delimiters:[{left:'$$',right:'$$',display:true},{left:'\\[',right:'\\]',display:true},{left:'$',right:'$',display:false},{left:'\\(',right:'\\)',display:false}],throwOnError:false})"></script>
+<style>
+body { max-width: 800px; margin: 2em auto; padding: 0 1em; font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; line-height: 1.7; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+h3 { font-size: 1.1em; }
+img { display: block; max-width: 100%; margin: .5em 0; }
+.answer { font-weight: bold; }
+@media print {
+  body { max-width: none; margin: 0; }
+  h2, h3 { break-after: avoid; }
+  .step, img { break-inside: avoid; }
+}
+` + extraCSS + `</style>
+</head>
+<body>
+`
+}
+
+var htmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+	// 普通美元符号单独成节点，避免 KaTeX 把它当作公式定界符
+	"$", "<span>$</span>",
+)
+
+// htmlText 将 Markdown + LaTeX 正文转为 HTML：公式保留定界符交给 KaTeX 渲染，文字转义后支持粗体、列表与换行
+func htmlText(s string) string {
+	return renderText(s, textFormat{
+		escape:    htmlEscaper.Replace,
+		math:      func(seg segment) string { return html.EscapeString(seg.Raw) },
+		boldOpen:  "<strong>",
+		boldClose: "</strong>",
+		bullet:    "• ",
+		newline:   "<br>\n",
+	})
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"strings"
+)
+
+// LaTeX 渲染为可直接编译的 .tex（article 文档类 + ctex 中文支持，推荐 xelatex）。
+// 公式原样保留，文字中的特殊字符转义；图片无法内嵌，以链接形式给出，data URI 图片省略
+func LaTeX(d *Document) []byte {
+	var b strings.Builder
+	b.WriteString(`% 由 gomath 导出，含中文，请使用 xelatex 编译
+\documentclass[11pt]{article}
+\usepackage[UTF8]{ctex}
+\usepackage{amsmath,amssymb}
+\usepackage[margin=2.5cm]{geometry}
+\usepackage{hyperref}
+`)
+	b.WriteString(`\title{` + latexText(oneLine(d.Title)) + "}\n\\date{}\n\n\\begin{document}\n\\maketitle\n\n")
+	b.WriteString("\\section*{题目}\n")
+	if strings.TrimSpace(d.Problem) != "" {
+		b.WriteString(latexText(strings.TrimSpace(d.Problem)) + "\n\n")
+	}
+	if link := latexImageLink(d.ProblemImage, "题目图片"); link != "" {
+		b.WriteString(link + "\n\n")
+	}
+	b.WriteString("\\section*{解析}\n")
+	for i, st := range d.Steps {
+		b.WriteString(`\subsection*{` + latexText(oneLine(stepTitle(i, st))) + "}\n")
+		if c := strings.TrimSpace(st.Content); c != "" {
+			b.WriteString(latexText(c) + "\n\n")
+		}
+		if link := latexImageLink(st.Image, "配图"); link != "" {
+			b.WriteString(link + "\n\n")
+		}
+	}
+	if strings.TrimSpace(d.Answer) != "" {
+		b.WriteString("\\section*{答案}\n" + latexText(strings.TrimSpace(d.Answer)) + "\n\n")
+	}
+	b.WriteString("\\end{document}\n")
+	return []byte(b.String())
+}
+
+// latexImageLink 图片链接；非 http(s) 图片（如 data URI）无法在 .tex 中引用，返回空
+func latexImageLink(url, label string) string {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return ""
+	}
+	r := strings.NewReplacer(`\`, "", "{", "%7B", "}", "%7D", "%", `\%`, "#", `\#`)
+	return `\noindent\href{` + r.Replace(url) + `}{[` + label + `]}`
+}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"&", `\&`,
+	"%", `\%`,
+	"#", `\#`,
+	"_", `\_`,
+	"$", `\$`,
+	"^", `\^{}`,
+	"~", `\textasciitilde{}`,
+)
+
+// latexText 将 Markdown + LaTeX 正文转为 LaTeX：公式原样保留（$$...$$ 转为 \[...\]），文字转义特殊字符
+func latexText(s string) string {
+	return renderText(s, textFormat{
+		escape: latexEscaper.Replace,
+		math: func(seg segment) string {
+			if seg.Display {
+				return `\[` + seg.Body + `\]`
+			}
+			return seg.Raw
+		},
+		boldOpen:  `\textbf{`,
+		boldClose: "}",
+		bullet:    `\textbullet{} `,
+		newline:   "\n\n",
+	})
+}
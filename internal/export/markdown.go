@@ -0,0 +1,37 @@
+package export
+
+import (
+	"strings"
+)
+
+// Markdown 渲染为 Markdown：正文原样保留（公式仍为 $...$），图片为链接或内嵌 data URI
+func Markdown(d *Document) []byte {
+	var b strings.Builder
+	b.WriteString("# " + oneLine(d.Title) + "\n\n")
+	b.WriteString("## 题目\n\n")
+	if strings.TrimSpace(d.Problem) != "" {
+		b.WriteString(strings.TrimSpace(d.Problem) + "\n\n")
+	}
+	if d.ProblemImage != "" {
+		b.WriteString("![题目](" + d.ProblemImage + ")\n\n")
+	}
+	b.WriteString("## 解析\n\n")
+	for i, st := range d.Steps {
+		b.WriteString("### " + oneLine(stepTitle(i, st)) + "\n\n")
+		if c := strings.TrimSpace(st.Content); c != "" {
+			b.WriteString(c + "\n\n")
+		}
+		if st.Image != "" {
+			b.WriteString("![" + oneLine(stepTitle(i, st)) + "](" + st.Image + ")\n\n")
+		}
+	}
+	if strings.TrimSpace(d.Answer) != "" {
+		b.WriteString("## 答案\n\n" + strings.TrimSpace(d.Answer) + "\n")
+	}
+	return []byte(b.String())
+}
+
+// oneLine 标题类文字压成一行
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
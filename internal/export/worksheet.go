@@ -0,0 +1,82 @@
+package export
+
+import (
+	"html"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Worksheet 练习卷：多道题，Answers 为 false 时只印题目并留作答空白，为 true 时为附解析与答案的答案卷
+type Worksheet struct {
+	Title     string
+	Subtitle  string // 标题下方的说明，如题序种子
+	Problems  []Document
+	Answers   bool
+	Numbering bool // 题目前加序号
+	SpaceCM   int  // 每题下方留白高度（厘米），0 表示不留白；答案卷忽略
+}
+
+// Shuffle 按种子打乱题目顺序，相同种子得到相同顺序，练习卷与答案卷据此保持一致
+func (w *Worksheet) Shuffle(seed int64) {
+	rand.New(rand.NewSource(seed)).Shuffle(len(w.Problems), func(i, j int) {
+		w.Problems[i], w.Problems[j] = w.Problems[j], w.Problems[i]
+	})
+}
+
+const worksheetCSS = `.problem { margin: 1.2em 0; break-inside: avoid; }
+.problem > .head { display: flex; gap: .5em; }
+.problem .no { font-weight: bold; white-space: nowrap; }
+.work { border-bottom: 1px dashed #bbb; }
+.solution { margin-top: .5em; padding-left: 1em; border-left: 3px solid #ddd; }
+.subtitle { color: #666; }
+@media print {
+  .work { border: none; }
+}
+`
+
+// WorksheetHTML 渲染为可打印的 HTML 练习卷或答案卷
+func WorksheetHTML(w *Worksheet) []byte {
+	var b strings.Builder
+	title := html.EscapeString(oneLine(w.Title))
+	b.WriteString(htmlHead(title, worksheetCSS))
+	b.WriteString("<h1>" + title + "</h1>\n")
+	if w.Subtitle != "" {
+		b.WriteString(`<p class="subtitle">` + html.EscapeString(w.Subtitle) + "</p>\n")
+	}
+	for i, p := range w.Problems {
+		b.WriteString(`<div class="problem">` + "\n" + `<div class="head">`)
+		if w.Numbering {
+			b.WriteString(`<span class="no">` + strconv.Itoa(i+1) + ".</span>")
+		}
+		b.WriteString(`<div class="content">` + htmlText(strings.TrimSpace(p.Problem)))
+		if p.ProblemImage != "" {
+			b.WriteString(`<img src="` + html.EscapeString(p.ProblemImage) + `" alt="题目">`)
+		}
+		b.WriteString("</div></div>\n")
+		if w.Answers {
+			b.WriteString(`<div class="solution">` + "\n")
+			if len(p.Steps) == 0 && strings.TrimSpace(p.Answer) == "" {
+				b.WriteString("<p>暂无解析</p>\n")
+			}
+			for j, st := range p.Steps {
+				b.WriteString("<h3>" + htmlText(oneLine(stepTitle(j, st))) + "</h3>\n")
+				if c := strings.TrimSpace(st.Content); c != "" {
+					b.WriteString(`<div class="content">` + htmlText(c) + "</div>\n")
+				}
+				if st.Image != "" {
+					b.WriteString(`<img src="` + html.EscapeString(st.Image) + `" alt="` + html.EscapeString(oneLine(stepTitle(j, st))) + `">` + "\n")
+				}
+			}
+			if a := strings.TrimSpace(p.Answer); a != "" {
+				b.WriteString(`<p class="answer">答案：` + htmlText(a) + "</p>\n")
+			}
+			b.WriteString("</div>\n")
+		} else if w.SpaceCM > 0 {
+			b.WriteString(`<div class="work" style="height:` + strconv.Itoa(w.SpaceCM) + `cm"></div>` + "\n")
+		}
+		b.WriteString("</div>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,163 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// index 历史的内存索引：按时间排序的条目 + 全文检索倒排表，增删改时增量维护，查询时无需整体排序
+type index struct {
+	order []*Item                        // 按 (At, ID) 升序
+	byID  map[string]*Item               // id → 条目
+	docs  map[string]string              // id → 规范化后的检索文本（题目 + 步骤标题与正文 + 错题笔记）
+	grams map[string]map[string]struct{} // 单字与相邻双字 → 含该片段的条目 id
+}
+
+func newIndex() *index {
+	return &index{
+		byID:  make(map[string]*Item),
+		docs:  make(map[string]string),
+		grams: make(map[string]map[string]struct{}),
+	}
+}
+
+// itemLess 条目排序：先按时间，同一时间按 id，保证游标位置唯一
+func itemLess(aAt int64, aID string, bAt int64, bID string) bool {
+	return aAt < bAt || (aAt == bAt && aID < bID)
+}
+
+func (x *index) add(it *Item) {
+	i := sort.Search(len(x.order), func(i int) bool {
+		return !itemLess(x.order[i].At, x.order[i].ID, it.At, it.ID)
+	})
+	x.order = append(x.order, nil)
+	copy(x.order[i+1:], x.order[i:])
+	x.order[i] = it
+	x.byID[it.ID] = it
+	x.indexText(it)
+}
+
+func (x *index) remove(id string) bool {
+	it, ok := x.byID[id]
+	if !ok {
+		return false
+	}
+	i := sort.Search(len(x.order), func(i int) bool {
+		return !itemLess(x.order[i].At, x.order[i].ID, it.At, it.ID)
+	})
+	if i < len(x.order) && x.order[i] == it {
+		x.order = append(x.order[:i], x.order[i+1:]...)
+	}
+	delete(x.byID, id)
+	x.unindexText(id)
+	return true
+}
+
+// reindex 条目内容（如解析结果）变化后更新检索文本
+func (x *index) reindex(it *Item) {
+	x.unindexText(it.ID)
+	x.indexText(it)
+}
+
+func (x *index) indexText(it *Item) {
+	doc := searchText(it)
+	x.docs[it.ID] = doc
+	for g := range gramsOf(doc) {
+		set := x.grams[g]
+		if set == nil {
+			set = make(map[string]struct{})
+			x.grams[g] = set
+		}
+		set[it.ID] = struct{}{}
+	}
+}
+
+func (x *index) unindexText(id string) {
+	for g := range gramsOf(x.docs[id]) {
+		if set := x.grams[g]; set != nil {
+			delete(set, id)
+			if len(set) == 0 {
+				delete(x.grams, g)
+			}
+		}
+	}
+	delete(x.docs, id)
+}
+
+// match 返回包含全部检索词的条目 id：先用倒排表求交集得到候选，再逐条确认检索词连续出现
+func (x *index) match(text string) map[string]struct{} {
+	terms := strings.Fields(normalizeText(text))
+	if len(terms) == 0 {
+		return nil
+	}
+	var cand map[string]struct{}
+	for _, term := range terms {
+		for g := range gramsOf(term) {
+			set := x.grams[g]
+			if cand == nil {
+				cand = make(map[string]struct{}, len(set))
+				for id := range set {
+					cand[id] = struct{}{}
+				}
+				continue
+			}
+			for id := range cand {
+				if _, ok := set[id]; !ok {
+					delete(cand, id)
+				}
+			}
+		}
+	}
+	for id := range cand {
+		doc := x.docs[id]
+		for _, term := range terms {
+			if !strings.Contains(doc, term) {
+				delete(cand, id)
+				break
+			}
+		}
+	}
+	return cand
+}
+
+// searchText 条目的检索文本：题目文字 + 各步骤标题与正文 + 最终答案 + 错题笔记与错误答案
+func searchText(it *Item) string {
+	var b strings.Builder
+	b.WriteString(it.Text)
+	if it.Mistake != nil {
+		b.WriteString("\n" + it.Mistake.Note + "\n" + it.Mistake.WrongAnswer)
+	}
+	if it.Result != nil {
+		for _, st := range it.Result.Steps {
+			b.WriteString("\n")
+			b.WriteString(st.Title)
+			b.WriteString("\n")
+			b.WriteString(st.Content)
+		}
+		b.WriteString("\n" + it.Result.Answer)
+	}
+	return normalizeText(b.String())
+}
+
+// normalizeText 转小写，非字母数字（含标点、公式符号）视为分隔
+func normalizeText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// gramsOf 返回文本中每个词的单字与相邻双字片段，中文无需分词即可检索任意子串
+func gramsOf(s string) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, w := range strings.Fields(s) {
+		rs := []rune(w)
+		for i := range rs {
+			out[string(rs[i])] = struct{}{}
+			if i+1 < len(rs) {
+				out[string(rs[i:i+2])] = struct{}{}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// FileVersion 当前历史文件格式版本。版本 1 为早期格式：条目数组、无版本字段、无创建/更新时间；
+// 版本 2 起为 {"version": N, "items": [...]}；版本 3 起条目带解析状态；版本 4 起解析结果按版本保存。
+// 修改条目的存储格式时递增版本并在 migrations 中补充升级步骤
+const FileVersion = 4
+
+// ErrNewerVersion 历史文件由更新版本的程序写入，无法安全读取（不会覆盖或当作损坏处理）
+var ErrNewerVersion = errors.New("history file version is newer than supported")
+
+// fileEnvelope 历史文件格式
+type fileEnvelope struct {
+	Version int             `json:"version"`
+	Items   json.RawMessage `json:"items"`
+}
+
+// migrations[v] 将版本 v 的条目（原始 JSON 对象，数字为 json.Number）原地升级为版本 v+1
+var migrations = map[int]func(items []map[string]any) error{
+	1: migrateV1,
+	2: migrateV2,
+	3: migrateV3,
+}
+
+// migrateV1 补充创建与更新时间：早期条目只有客户端提供的 at，以其作为两者的初值
+func migrateV1(items []map[string]any) error {
+	for _, it := range items {
+		at, ok := it["at"].(json.Number)
+		if !ok {
+			at = json.Number("0")
+		}
+		if _, ok := it["created_at"]; !ok {
+			it["created_at"] = at
+		}
+		if _, ok := it["updated_at"]; !ok {
+			it["updated_at"] = at
+		}
+	}
+	return nil
+}
+
+// migrateV2 补充解析状态：此前结果由前端写回，已有结果的条目视为解析完成
+func migrateV2(items []map[string]any) error {
+	for _, it := range items {
+		if r, ok := it["result"]; ok && r != nil {
+			if _, ok := it["status"]; !ok {
+				it["status"] = StatusDone
+			}
+		}
+	}
+	return nil
+}
+
+// migrateV3 已有的单个解析结果作为第一个版本并设为当前版本
+func migrateV3(items []map[string]any) error {
+	for _, it := range items {
+		r, ok := it["result"]
+		if !ok || r == nil {
+			continue
+		}
+		if _, ok := it["versions"]; ok {
+			continue
+		}
+		v := map[string]any{"id": uuid.New().String(), "result": r, "created_at": it["updated_at"]}
+		for _, k := range []string{"task_id", "model"} {
+			if x, ok := it[k]; ok {
+				v[k] = x
+			}
+		}
+		it["versions"] = []any{v}
+		it["preferred"] = v["id"]
+	}
+	return nil
+}
+
+// decodeFile 解析历史文件并升级到 FileVersion，返回条目与文件原来的版本
+func decodeFile(data []byte) ([]*Item, int, error) {
+	data = bytes.TrimSpace(data)
+	version, raw := 1, json.RawMessage(data)
+	if len(data) > 0 && data[0] == '{' {
+		var env fileEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, 0, err
+		}
+		if env.Version < 2 {
+			return nil, 0, fmt.Errorf("invalid history file version %d", env.Version)
+		}
+		version, raw = env.Version, env.Items
+	}
+	if version > FileVersion {
+		return nil, version, fmt.Errorf("%w: version %d, supported up to %d", ErrNewerVersion, version, FileVersion)
+	}
+	if version < FileVersion {
+		var objs []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&objs); err != nil {
+			return nil, version, err
+		}
+		for v := version; v < FileVersion; v++ {
+			if err := migrations[v](objs); err != nil {
+				return nil, version, fmt.Errorf("migrate history from version %d: %w", v, err)
+			}
+		}
+		var err error
+		if raw, err = json.Marshal(objs); err != nil {
+			return nil, version, err
+		}
+	}
+	var items []*Item
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, version, err
+		}
+	}
+	return items, version, nil
+}
+
+// encodeFile 按当前版本格式序列化条目
+func encodeFile(items []Item) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Version int    `json:"version"`
+		Items   []Item `json:"items"`
+	}{FileVersion, items}, "", "  ")
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.json")
+	legacy := `[{"id":"a","type":"text","text":"旧题","at":1700000000123,"result":{"steps":[{"title":"t","content":"c"}]}}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(path, config.HistoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, ok := s.Get("a")
+	if !ok || it.CreatedAt != 1700000000123 || it.UpdatedAt != 1700000000123 || it.Result == nil || it.Status != StatusDone ||
+		len(it.Versions) != 1 || it.Preferred != it.Versions[0].ID {
+		t.Fatalf("migrated item = %+v", it)
+	}
+	// 升级前的原文件保留，新文件带版本号
+	if b, err := os.ReadFile(path + ".v1.bak"); err != nil || string(b) != legacy {
+		t.Errorf("pre-migration backup = %q, %v", b, err)
+	}
+	var env struct {
+		Version int    `json:"version"`
+		Items   []Item `json:"items"`
+	}
+	b, _ := os.ReadFile(path)
+	if err := json.Unmarshal(b, &env); err != nil || env.Version != FileVersion || len(env.Items) != 1 {
+		t.Errorf("rewritten file = %s", b)
+	}
+
+	// 更新版本写入的文件：报错且原样保留，不当作损坏从备份恢复
+	newer := `{"version":99,"items":[]}`
+	os.WriteFile(path, []byte(newer), 0644)
+	if _, err := NewStore(path, config.HistoryConfig{}); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("newer version err = %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != newer {
+		t.Errorf("newer file modified: %s", b)
+	}
+}
@@ -0,0 +1,60 @@
+package history
+
+import "time"
+
+// 错题原因分类
+const (
+	CategoryConcept     = "concept"     // 概念不清
+	CategoryCalculation = "calculation" // 计算错误
+	CategoryCareless    = "careless"    // 审题不清、粗心
+	CategoryMethod      = "method"      // 思路或方法错误
+	CategoryOther       = "other"
+)
+
+// MistakeCategories 全部错题分类
+var MistakeCategories = []string{CategoryConcept, CategoryCalculation, CategoryCareless, CategoryMethod, CategoryOther}
+
+// ValidCategory 分类为空或属于 MistakeCategories
+func ValidCategory(c string) bool {
+	if c == "" {
+		return true
+	}
+	for _, v := range MistakeCategories {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Mistake 错题本信息：条目带有 Mistake 即为错题，错题默认不参与保留策略清理
+type Mistake struct {
+	Note        string `json:"note,omitempty"`         // 用户笔记
+	WrongAnswer string `json:"wrong_answer,omitempty"` // 学生当时的错误答案
+	Category    string `json:"category,omitempty"`     // 错误原因分类，见 MistakeCategories
+	At          int64  `json:"at"`                     // 加入错题本的时间（毫秒）
+}
+
+// SetMistake 按 id 标记为错题或更新错题信息，m 为 nil 时移出错题本；已是错题时保留最初加入的时间
+func (s *Store) SetMistake(id string, m *Mistake) bool {
+	s.mu.Lock()
+	it, ok := s.idx.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	if m != nil {
+		cp := *m
+		cp.At = time.Now().UnixMilli()
+		if it.Mistake != nil {
+			cp.At = it.Mistake.At
+		}
+		m = &cp
+	}
+	it.Mistake = m
+	it.UpdatedAt = time.Now().UnixMilli()
+	s.idx.reindex(it)
+	s.mu.Unlock()
+	s.changed()
+	return true
+}
@@ -0,0 +1,181 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gomath/gomath/internal/persist"
+)
+
+// errEmptyFile 历史文件为空（写入中途崩溃可能留下空文件）
+var errEmptyFile = errors.New("empty file")
+
+// load 读取历史文件，旧版本格式升级后先保留原文件（history.json.v<N>.bak）再按新格式写回；
+// 文件损坏或为空时依次尝试备份，用最近的完好备份恢复，损坏的文件改名保留以便排查
+func (s *Store) load() error {
+	items, version, err := readItems(s.filePath)
+	if errors.Is(err, ErrNewerVersion) {
+		return err
+	}
+	restored := false
+	if err != nil && !os.IsNotExist(err) {
+		for i := 1; i <= s.cfg.BackupCount(); i++ {
+			bak := s.backupPath(i)
+			backup, _, berr := readItems(bak)
+			if berr != nil {
+				continue
+			}
+			log.Printf("[history] %s unreadable (%v), restored %d items from %s", s.filePath, err, len(backup), bak)
+			if errors.Is(err, errEmptyFile) {
+				os.Remove(s.filePath)
+			} else {
+				corrupt := s.filePath + ".corrupt-" + time.Now().Format("20060102-150405")
+				if rerr := os.Rename(s.filePath, corrupt); rerr != nil {
+					return fmt.Errorf("keep corrupt history file: %w", rerr)
+				}
+			}
+			items, err, restored = backup, nil, true
+			break
+		}
+		if errors.Is(err, errEmptyFile) {
+			// 没有可用备份时，空文件按空历史处理
+			items, err = nil, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	idx := newIndex()
+	for _, it := range items {
+		if it == nil {
+			continue
+		}
+		if it.Status == StatusPending {
+			// 上次退出时解析尚未完成，不会再有结果写回
+			it.Status, it.Error = StatusFailed, "interrupted"
+		}
+		idx.add(it)
+	}
+	s.mu.Lock()
+	s.idx = idx
+	s.mu.Unlock()
+	if fi, err := os.Stat(s.backupPath(1)); err == nil {
+		s.lastBackup = fi.ModTime()
+	}
+	if !restored && version > 0 && version < FileVersion {
+		bak := fmt.Sprintf("%s.v%d.bak", s.filePath, version)
+		if err := copyFile(s.filePath, bak); err != nil {
+			return fmt.Errorf("backup before migration: %w", err)
+		}
+		log.Printf("[history] migrated %s from version %d to %d, original kept as %s", s.filePath, version, FileVersion, bak)
+		return s.write()
+	}
+	if restored {
+		return s.write()
+	}
+	return nil
+}
+
+// readItems 读取并解析历史文件（旧版本在内存中升级），返回条目与文件原来的版本
+func readItems(path string) ([]*Item, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) == 0 {
+		return nil, 0, errEmptyFile
+	}
+	return decodeFile(data)
+}
+
+// changed 条目修改后调用（不得持有 s.mu）：等待 SaveDelay 后在后台写入一次，期间的多次修改合并
+func (s *Store) changed() {
+	s.saver.Changed()
+}
+
+// Flush 立即写入尚未落盘的修改；写入失败时修改仍保留，下次修改或 Flush 时重试
+func (s *Store) Flush() error {
+	return s.saver.Flush()
+}
+
+// Close 写入尚未落盘的修改，之后的修改不再延迟，立即写入；进程退出前调用
+func (s *Store) Close() error {
+	return s.saver.Close()
+}
+
+// write 将当前全部条目写入文件：先写同目录临时文件（可选 fsync），再原子替换，替换前按间隔轮换备份
+func (s *Store) write() error {
+	s.mu.RLock()
+	// 新在前，与之前的文件内容顺序一致
+	snapshot := make([]Item, 0, len(s.idx.order))
+	for i := len(s.idx.order) - 1; i >= 0; i-- {
+		snapshot = append(snapshot, *s.idx.order[i])
+	}
+	s.mu.RUnlock()
+	data, err := encodeFile(snapshot)
+	if err != nil {
+		return err
+	}
+	// 备份以硬链接（或复制）保留当前文件，随后的替换不影响备份内容
+	if err := s.rotateBackups(); err != nil {
+		// 备份失败不影响写入
+		log.Printf("[history] backup: %v", err)
+	}
+	return persist.WriteFile(s.filePath, data, 0644, s.cfg.Fsync)
+}
+
+// backupPath 第 i 份备份（1 为最新）
+func (s *Store) backupPath(i int) string {
+	return s.filePath + ".bak." + strconv.Itoa(i)
+}
+
+// rotateBackups 距上次备份超过 BackupInterval 时，将当前文件（上一次完整写入的内容）存为最新备份，旧备份依次后移
+func (s *Store) rotateBackups() error {
+	n := s.cfg.BackupCount()
+	if time.Since(s.lastBackup) < s.cfg.BackupInterval() {
+		return nil
+	}
+	if _, err := os.Stat(s.filePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for i := n - 1; i >= 1; i-- {
+		if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	bak := s.backupPath(1)
+	os.Remove(bak)
+	// 优先硬链接（随后原文件被替换，链接仍指向旧内容），不支持时复制
+	if err := os.Link(s.filePath, bak); err != nil {
+		if err := copyFile(s.filePath, bak); err != nil {
+			return err
+		}
+	}
+	s.lastBackup = time.Now()
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
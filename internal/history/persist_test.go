@@ -0,0 +1,91 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+func TestPersist(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.json")
+	cfg := config.HistoryConfig{SaveDelayMs: 60_000} // 测试期间定时器不会触发，由 Flush 写入
+	s, err := NewStore(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(Item{ID: "a", Type: "text", Text: "第一题", At: 1})
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 合并写入：等待期间的多次修改不落盘，之后写入一次；第二次写入前轮换出第一份备份
+	s.Add(Item{ID: "b", Type: "text", Text: "第二题", At: 2})
+	s.Add(Item{ID: "c", Type: "text", Text: "第三题", At: 3})
+	done := make(chan bool)
+	go func() { done <- s.Delete("c") }() // 删除曾在持有写锁时保存而死锁
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Delete deadlocked")
+	}
+	if n := loadCount(t, path, cfg); n != 1 {
+		t.Fatalf("before flush: %d items, want 1", n)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := loadCount(t, path, cfg); n != 2 {
+		t.Fatalf("after coalesced save: %d items, want 2", n)
+	}
+	if tmp, _ := filepath.Glob(path + ".tmp-*"); len(tmp) != 0 {
+		t.Errorf("temp files left: %v", tmp)
+	}
+	if _, err := os.Stat(s.backupPath(1)); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	s.MarkPending("b")
+	s.Close()
+
+	// 退出时仍在解析中的条目不会再有结果写回，重新加载后标记为失败
+	s2, err := NewStore(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it, _ := s2.Get("b"); it.Status != StatusFailed {
+		t.Errorf("interrupted item status = %q, want failed", it.Status)
+	}
+
+	// 主文件损坏时从最近的备份恢复，损坏的文件改名保留
+	if err := os.WriteFile(path, []byte(`[{"id":"a",`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n := loadCount(t, path, cfg); n != 1 {
+		t.Fatalf("after recovery: %d items, want 1 (backup)", n)
+	}
+	if corrupt, _ := filepath.Glob(path + ".corrupt-*"); len(corrupt) != 1 {
+		t.Errorf("corrupt file not kept: %v", corrupt)
+	}
+	if _, _, err := readItems(path); err != nil {
+		t.Errorf("restored file not written: %v", err)
+	}
+
+	// 没有可用备份时损坏的文件报错，不覆盖
+	os.WriteFile(path, []byte("{"), 0644)
+	os.Remove(s.backupPath(1))
+	if _, err := NewStore(path, cfg); err == nil {
+		t.Error("corrupt file without backup should fail")
+	}
+}
+
+func loadCount(t *testing.T, path string, cfg config.HistoryConfig) int {
+	t.Helper()
+	s, err := NewStore(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := s.Search(Query{Limit: MaxLimit})
+	return len(page.Items)
+}
@@ -0,0 +1,103 @@
+package history
+
+import "time"
+
+// PruneResult 一次清理的结果：Paths、TaskIDs 为被清理条目引用、且不再被其余条目引用的上传图片与解析任务，可一并删除
+type PruneResult struct {
+	Removed []Item
+	Paths   []string
+	TaskIDs []string
+}
+
+// Prune 按保留策略清理历史：先删过期条目，再按每用户条数、总条数从最早的开始删；置顶条目与错题默认不参与
+func (s *Store) Prune(now time.Time) PruneResult {
+	maxAge := s.cfg.MaxAge()
+	perUser := s.cfg.MaxItemsPerUser
+	maxItems := s.cfg.MaxItemCount()
+	exempt := func(it *Item) bool { return (it.Pinned || it.Mistake != nil) && !s.cfg.PrunePinned }
+
+	s.mu.Lock()
+	order := s.idx.order
+	removed := make(map[string]bool)
+	if maxAge > 0 {
+		cutoff := now.Add(-maxAge).UnixMilli()
+		for _, it := range order {
+			if it.At >= cutoff {
+				break
+			}
+			if !exempt(it) {
+				removed[it.ID] = true
+			}
+		}
+	}
+	if perUser > 0 {
+		counts := make(map[string]int)
+		for i := len(order) - 1; i >= 0; i-- {
+			it := order[i]
+			if removed[it.ID] || exempt(it) {
+				continue
+			}
+			counts[it.UserID]++
+			if counts[it.UserID] > perUser {
+				removed[it.ID] = true
+			}
+		}
+	}
+	for i, left := 0, len(order)-len(removed); i < len(order) && left > maxItems; i++ {
+		if it := order[i]; !removed[it.ID] && !exempt(it) {
+			removed[it.ID] = true
+			left--
+		}
+	}
+	if len(removed) == 0 {
+		s.mu.Unlock()
+		return PruneResult{}
+	}
+
+	var res PruneResult
+	paths := make(map[string]bool)
+	tasks := make(map[string]bool)
+	for id := range removed {
+		it := s.idx.byID[id]
+		res.Removed = append(res.Removed, *it)
+		if it.Type == "upload" && it.Path != "" {
+			paths[it.Path] = true
+		}
+		for _, t := range it.taskIDs() {
+			tasks[t] = true
+		}
+		s.idx.remove(id)
+	}
+	// 仍被其余条目引用的图片与结果保留
+	for _, it := range s.idx.order {
+		delete(paths, it.Path)
+		for _, t := range it.taskIDs() {
+			delete(tasks, t)
+		}
+	}
+	for p := range paths {
+		res.Paths = append(res.Paths, p)
+	}
+	for t := range tasks {
+		res.TaskIDs = append(res.TaskIDs, t)
+	}
+	s.mu.Unlock()
+	s.changed()
+	return res
+}
+
+// RemoveUnreferenced 在没有条目引用上传图片 path 时调用 remove 删除文件，返回是否已删除。
+// 检查与删除期间持有写锁，Prune 之后新增的引用同一图片的条目不会丢失图片
+func (s *Store) RemoveUnreferenced(path string, remove func() error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, it := range s.idx.order {
+		if it.Path == path {
+			return false, nil
+		}
+	}
+	if err := remove(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
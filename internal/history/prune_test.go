@@ -0,0 +1,91 @@
+package history
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.UnixMilli(10 * 24 * 3600 * 1000)
+	day := int64(24 * 3600 * 1000)
+	s, _ := NewStore("", config.HistoryConfig{MaxItems: 5, MaxItemsPerUser: 2, MaxAgeDays: 5})
+	s.Add(Item{ID: "old", Type: "upload", Path: "old.png", TaskID: "t-old", At: now.UnixMilli() - 6*day})
+	s.Add(Item{ID: "old-pinned", Type: "text", At: now.UnixMilli() - 7*day, Pinned: true})
+	s.Add(Item{ID: "old-mistake", Type: "text", At: now.UnixMilli() - 8*day, Mistake: &Mistake{Category: CategoryCareless}})
+	s.Add(Item{ID: "shared", Type: "upload", Path: "shared.png", At: now.UnixMilli() - 7*day})
+	s.Add(Item{ID: "a1", UserID: "a", Type: "upload", Path: "shared.png", At: now.UnixMilli() - 3*day})
+	s.Add(Item{ID: "a2", UserID: "a", Type: "text", At: now.UnixMilli() - 2*day})
+	s.Add(Item{ID: "a3", UserID: "a", Type: "text", At: now.UnixMilli() - 1*day})
+	s.Add(Item{ID: "b1", UserID: "b", Type: "text", At: now.UnixMilli() - 4*day})
+	s.Add(Item{ID: "b2", UserID: "b", Type: "text", At: now.UnixMilli() - 1*day})
+
+	res := s.Prune(now)
+	var removed []string
+	for _, it := range res.Removed {
+		removed = append(removed, it.ID)
+	}
+	sort.Strings(removed)
+	// 过期：old、shared；a 超出每用户 2 条：a1；剩 old-mistake old-pinned a2 a3 b1 b2 超出总数 5：b1（置顶与错题计入总数但不被清理）
+	if want := []string{"a1", "b1", "old", "shared"}; !equal(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	sort.Strings(res.Paths)
+	if !equal(res.Paths, []string{"old.png", "shared.png"}) || !equal(res.TaskIDs, []string{"t-old"}) {
+		t.Errorf("orphans = %v %v", res.Paths, res.TaskIDs)
+	}
+	for _, id := range []string{"old-pinned", "old-mistake"} {
+		if _, ok := s.Get(id); !ok {
+			t.Errorf("%s should be kept", id)
+		}
+	}
+	if res := s.Prune(now); len(res.Removed) != 0 {
+		t.Errorf("second prune removed %d items", len(res.Removed))
+	}
+
+	// 清理后又有条目引用 old.png 时不再删除
+	s.Add(Item{ID: "again", Type: "upload", Path: "old.png", At: now.UnixMilli()})
+	calls := 0
+	remove := func() error { calls++; return nil }
+	if ok, _ := s.RemoveUnreferenced("old.png", remove); ok || calls != 0 {
+		t.Errorf("RemoveUnreferenced(old.png) = %v, calls %d; want kept", ok, calls)
+	}
+	if ok, _ := s.RemoveUnreferenced("shared.png", remove); !ok || calls != 1 {
+		t.Errorf("RemoveUnreferenced(shared.png) = %v, calls %d; want removed", ok, calls)
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	now := time.UnixMilli(10 * 24 * 3600 * 1000)
+	day := int64(24 * 3600 * 1000)
+	s, _ := NewStore("", config.HistoryConfig{MaxAgeDays: 5})
+	result := &explanation.Result{Answer: "x = 3"}
+	s.Add(Item{ID: "old", Type: "text", Text: "2x + 4 = 10", At: now.UnixMilli() - 6*day})
+	s.Add(Item{ID: "keep", Type: "text", Text: "2x + 4 = 10", At: now.UnixMilli() - 1*day})
+	// old 有三个版本，当前版本为 t2；t3 同时被 keep 的非当前版本引用
+	for _, t := range []string{"t1", "t2", "t3"} {
+		s.AddResult("old", result, t, "m")
+	}
+	v2 := ""
+	if it, _ := s.Get("old"); len(it.Versions) == 3 {
+		v2 = it.Versions[1].ID
+	}
+	if !s.SetPreferred("old", v2) {
+		t.Fatal("SetPreferred failed")
+	}
+	s.AddResult("keep", result, "t3", "m")
+	s.AddResult("keep", result, "t4", "m")
+
+	res := s.Prune(now)
+	if len(res.Removed) != 1 || res.Removed[0].ID != "old" {
+		t.Fatalf("removed = %+v, want old", res.Removed)
+	}
+	sort.Strings(res.TaskIDs)
+	// 非当前版本的结果一并清理，仍被 keep 的版本引用的 t3 保留
+	if want := []string{"t1", "t2"}; !equal(res.TaskIDs, want) {
+		t.Errorf("task ids = %v, want %v", res.TaskIDs, want)
+	}
+}
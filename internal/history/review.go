@@ -0,0 +1,26 @@
+package history
+
+import (
+	"time"
+
+	"github.com/gomath/gomath/internal/review"
+)
+
+// SetReview 按 id 更新间隔复习状态，c 为 nil 时清除
+func (s *Store) SetReview(id string, c *review.Card) bool {
+	s.mu.Lock()
+	it, ok := s.idx.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	if c != nil {
+		cp := *c
+		c = &cp
+	}
+	it.Review = c
+	it.UpdatedAt = time.Now().UnixMilli()
+	s.mu.Unlock()
+	s.changed()
+	return true
+}
@@ -0,0 +1,180 @@
+package history
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 分页大小：未指定时 DefaultLimit，最大 MaxLimit
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// ErrInvalidCursor 游标无法解析
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Query 历史检索条件，零值字段表示不限
+type Query struct {
+	UserID    string   // 只看该用户的历史
+	Text      string   // 全文检索：题目文字、步骤内容与错题笔记须包含所有空白分隔的词（不区分大小写）
+	Type      string   // "upload" | "text"
+	From      int64    // At ≥ From（毫秒）
+	To        int64    // At < To（毫秒）
+	HasResult *bool    // 是否已有解析结果
+	Tags      []string // 须带有全部标签
+	Mistake   *bool    // 是否为错题
+	Category  string   // 错题分类，非空时只看该分类的错题
+	DueBy     int64    // 非 0 时只看待复习的条目：复习到期（Due ≤ DueBy），或尚未复习过的错题
+	Oldest    bool     // 旧在前，默认新在前
+	Cursor    string   // 上一页返回的 NextCursor
+	Limit     int      // 每页条数，≤0 时 DefaultLimit，最大 MaxLimit
+}
+
+// Page 一页检索结果，NextCursor 为空表示没有更多
+type Page struct {
+	Items      []Item
+	NextCursor string
+}
+
+// Search 按条件检索历史：沿时间索引从游标处向前/向后扫描，全文检索先经倒排表筛出候选
+func (s *Store) Search(q Query) (Page, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	tags := normalizeTags(q.Tags)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	order := s.idx.order
+	var cand map[string]struct{}
+	if normalizeText(q.Text) != "" {
+		cand = s.idx.match(q.Text)
+	}
+
+	// 定位起点：新在前时从游标之前（更早）开始向前扫描，旧在前时从游标之后向后扫描
+	i, step := len(order)-1, -1
+	if q.Oldest {
+		i, step = 0, 1
+	}
+	if q.Cursor != "" {
+		at, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if q.Oldest {
+			i = sort.Search(len(order), func(j int) bool { return itemLess(at, id, order[j].At, order[j].ID) })
+		} else {
+			i = sort.Search(len(order), func(j int) bool { return !itemLess(order[j].At, order[j].ID, at, id) }) - 1
+		}
+	}
+
+	page := Page{Items: make([]Item, 0)}
+	for ; i >= 0 && i < len(order); i += step {
+		it := order[i]
+		if !matches(it, q, tags, cand) {
+			continue
+		}
+		if len(page.Items) == limit {
+			last := page.Items[len(page.Items)-1]
+			page.NextCursor = encodeCursor(last.At, last.ID)
+			break
+		}
+		page.Items = append(page.Items, *it)
+	}
+	return page, nil
+}
+
+func matches(it *Item, q Query, tags []string, cand map[string]struct{}) bool {
+	if q.UserID != "" && it.UserID != q.UserID {
+		return false
+	}
+	if q.Type != "" && it.Type != q.Type {
+		return false
+	}
+	if (q.From != 0 && it.At < q.From) || (q.To != 0 && it.At >= q.To) {
+		return false
+	}
+	if q.HasResult != nil && (it.Result != nil) != *q.HasResult {
+		return false
+	}
+	if q.Mistake != nil && (it.Mistake != nil) != *q.Mistake {
+		return false
+	}
+	if q.Category != "" && (it.Mistake == nil || it.Mistake.Category != q.Category) {
+		return false
+	}
+	if q.DueBy != 0 && !isDue(it, q.DueBy) {
+		return false
+	}
+	for _, t := range tags {
+		if !hasTag(it, t) {
+			return false
+		}
+	}
+	if cand != nil {
+		if _, ok := cand[it.ID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isDue(it *Item, by int64) bool {
+	if it.Review == nil {
+		return it.Mistake != nil
+	}
+	return it.Review.Due <= by
+}
+
+func hasTag(it *Item, tag string) bool {
+	for _, t := range it.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// 游标为最后一条的 (At, ID)，条目被删除后依然有效
+func encodeCursor(at int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(at, 10) + ":" + id))
+}
+
+func decodeCursor(c string) (int64, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+	atStr, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return 0, "", ErrInvalidCursor
+	}
+	at, err := strconv.ParseInt(atStr, 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+	return at, id, nil
+}
+
+// normalizeTags 去除空白与重复标签（不区分大小写），保留首次出现的写法
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/review"
+)
+
+func TestSearch(t *testing.T) {
+	s, _ := NewStore("", config.HistoryConfig{})
+	s.Add(Item{ID: "a", Type: "text", Text: "解方程 2x+3=7", At: 1, Tags: []string{"方程"}})
+	s.Add(Item{ID: "b", Type: "upload", Path: "b.png", At: 2})
+	s.Add(Item{ID: "c", Type: "text", Text: "求函数 f(x)=Sin x 的最小正周期", At: 3, UserID: "u1"})
+	s.Add(Item{ID: "d", Type: "text", Text: "一元二次方程", At: 3, Tags: []string{"方程", "错题"}})
+	s.AddResult("b", &explanation.Result{Steps: []explanation.StepResult{{Title: "移项", Content: "得到一元一次方程"}}}, "t1", "")
+	s.SetMistake("a", &Mistake{Note: "忘记变号", WrongAnswer: "x=5", Category: CategoryCalculation})
+	s.SetReview("d", &review.Card{Due: 5})
+
+	ids := func(p Page) (out []string) {
+		for _, it := range p.Items {
+			out = append(out, it.ID)
+		}
+		return out
+	}
+	yes := true
+	cases := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"newest first", Query{}, []string{"d", "c", "b", "a"}},
+		{"oldest first", Query{Oldest: true}, []string{"a", "b", "c", "d"}},
+		{"text and steps", Query{Text: "方程"}, []string{"d", "b", "a"}},
+		{"all terms, case-insensitive", Query{Text: "sin 周期"}, []string{"c"}},
+		{"substring must be contiguous", Query{Text: "方二"}, nil},
+		{"type", Query{Type: "upload"}, []string{"b"}},
+		{"has result", Query{HasResult: &yes}, []string{"b"}},
+		{"tags", Query{Tags: []string{"方程", "错题"}}, []string{"d"}},
+		{"date range", Query{From: 2, To: 3}, []string{"b"}},
+		{"user", Query{UserID: "u1"}, []string{"c"}},
+		{"mistakes", Query{Mistake: &yes}, []string{"a"}},
+		{"mistake category", Query{Category: CategoryMethod}, nil},
+		{"mistake note", Query{Text: "变号"}, []string{"a"}},
+		{"due for review", Query{DueBy: 5}, []string{"d", "a"}},
+		{"new mistakes always due", Query{DueBy: 4}, []string{"a"}},
+	}
+	for _, c := range cases {
+		p, err := s.Search(c.q)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := ids(p); !equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// 游标分页：逐页取完且不重不漏，翻页期间删除条目不影响后续页
+	var all []string
+	q := Query{Limit: 1}
+	for {
+		p, err := s.Search(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, ids(p)...)
+		if p.NextCursor == "" {
+			break
+		}
+		if len(all) == 1 {
+			s.Delete("c")
+		}
+		q.Cursor = p.NextCursor
+	}
+	if !equal(all, []string{"d", "b", "a"}) {
+		t.Errorf("paged = %v", all)
+	}
+	if _, err := s.Search(Query{Cursor: "!!"}); err != ErrInvalidCursor {
+		t.Errorf("bad cursor err = %v", err)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
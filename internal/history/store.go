@@ -1,193 +1,173 @@
 package history
 
 import (
-	"encoding/json"
-	"log"
 	"os"
-	"path/filepath"
-	"sort"
 	"sync"
+	"time"
 
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/persist"
+	"github.com/gomath/gomath/internal/review"
 	"github.com/google/uuid"
 )
 
-const maxItems = 50
-
-// Step 与 explanation 展示一致
-type Step struct {
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	ImageURL string `json:"image_url,omitempty"`
-}
+// 解析状态
+const (
+	StatusPending = "pending" // 解析中
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
 
 // Item 单条历史：上传或文字输入，可选带解析结果
 type Item struct {
-	ID     string  `json:"id"`
-	Type   string  `json:"type"` // "upload" | "text"
-	Path   string  `json:"path,omitempty"`
-	Text   string  `json:"text,omitempty"`
-	At     int64   `json:"at"`
-	Result *Result `json:"result,omitempty"`
-	TaskID string  `json:"task_id,omitempty"`
+	ID        string              `json:"id"`
+	UserID    string              `json:"user_id,omitempty"` // 所属用户，未开启鉴权时为空
+	Type      string              `json:"type"`              // "upload" | "text"
+	Path      string              `json:"path,omitempty"`
+	Text      string              `json:"text,omitempty"`
+	ProblemNo string              `json:"problem_no,omitempty"` // 多题图片中的题号，同一张图拆出的每道题各一条历史
+	Region    *media.Region       `json:"region,omitempty"`     // 上传图片中选定的区域（裁剪 + 旋转），重新解析时沿用
+	At        int64               `json:"at"`                   // 题目时间（毫秒），用于排序与按日期筛选；创建时由服务端赋值，导入时沿用归档中的时间
+	CreatedAt int64               `json:"created_at"`           // 写入本存储的时间（毫秒），服务端赋值
+	UpdatedAt int64               `json:"updated_at"`           // 最近一次修改的时间（毫秒），服务端赋值
+	Tags      []string            `json:"tags,omitempty"`       // 用户标签，可按标签筛选
+	Pinned    bool                `json:"pinned,omitempty"`     // 置顶：默认不参与保留策略清理
+	Mistake   *Mistake            `json:"mistake,omitempty"`    // 错题本信息，为空表示不是错题
+	Review    *review.Card        `json:"review,omitempty"`     // 间隔复习状态，为空表示尚未复习过
+	Status    string              `json:"status,omitempty"`     // 解析状态，空表示尚未解析，见 StatusPending 等
+	Result    *explanation.Result `json:"result,omitempty"`     // 当前采用的解析版本的结果，TaskID、Model 同
+	TaskID    string              `json:"task_id,omitempty"`
+	Model     string              `json:"model,omitempty"`     // 生成解析的模型标识（provider|model|prompt 版本）
+	Error     string              `json:"error,omitempty"`     // 最近一次解析失败的原因
+	Versions  []Version           `json:"versions,omitempty"`  // 历次解析结果，旧在前，最多 MaxVersions 个
+	Preferred string              `json:"preferred,omitempty"` // 当前采用的版本 ID
 }
 
-// Result 解析结果
-type Result struct {
-	Steps []Step `json:"steps"`
-}
-
-// Store 历史存储，内存 + 文件持久化
+// Store 历史存储，内存 + 文件持久化：修改后延迟合并写入，写临时文件再原子替换，并定期轮换备份
 type Store struct {
 	mu       sync.RWMutex
-	items    []*Item
+	idx      *index
+	cfg      config.HistoryConfig
 	filePath string
+
+	saver      *persist.Saver // 延迟合并写入，仅内存时为 nil
+	lastBackup time.Time
 }
 
-// NewStore 创建存储，filePath 为空则仅内存
-func NewStore(filePath string) (*Store, error) {
-	s := &Store{items: make([]*Item, 0), filePath: filePath}
+// NewStore 创建存储，filePath 为空则仅内存；cfg 为保留策略与持久化选项。文件损坏时从最近的完好备份恢复
+func NewStore(filePath string, cfg config.HistoryConfig) (*Store, error) {
+	s := &Store{idx: newIndex(), cfg: cfg, filePath: filePath}
 	if filePath != "" {
 		if err := s.load(); err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
+		s.saver = persist.NewSaver("history", cfg.SaveDelay(), s.write)
 	}
 	return s, nil
 }
 
-func (s *Store) load() error {
-	data, err := os.ReadFile(s.filePath)
-	if err != nil {
-		return err
-	}
-	if len(data) == 0 {
-		s.mu.Lock()
-		s.items = make([]*Item, 0)
-		s.mu.Unlock()
-		return nil
+// Get 按 id 获取一条历史副本
+func (s *Store) Get(id string) (*Item, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	it, ok := s.idx.byID[id]
+	if !ok {
+		return nil, false
 	}
-	var items []*Item
-	if err := json.Unmarshal(data, &items); err != nil {
-		return err
+	cp := *it
+	return &cp, true
+}
+
+// Add 新增一条，返回 id；创建与更新时间取当前时间，At 为 0 时同样取当前时间；只带 Result 的条目（如导入早期归档）作为第一个版本
+func (s *Store) Add(it Item) string {
+	if it.ID == "" {
+		it.ID = uuid.New().String()
 	}
-	if items == nil {
-		items = make([]*Item, 0)
+	now := time.Now().UnixMilli()
+	it.CreatedAt, it.UpdatedAt = now, now
+	if it.At == 0 {
+		it.At = now
 	}
+	it.Tags = normalizeTags(it.Tags)
+	it.normalizeVersions()
 	s.mu.Lock()
-	s.items = items
+	s.idx.add(&it)
 	s.mu.Unlock()
-	return nil
+	s.changed()
+	return it.ID
 }
 
-func (s *Store) save() error {
-	if s.filePath == "" {
-		return nil
-	}
-	s.mu.RLock()
-	snapshot := make([]Item, len(s.items))
-	for i, it := range s.items {
-		if it != nil {
-			snapshot[i] = *it
-		}
-	}
-	s.mu.RUnlock()
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return err
-	}
-	dir := filepath.Dir(s.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Printf("[history] save mkdir %s: %v", dir, err)
-		return err
-	}
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
-		log.Printf("[history] save write %s: %v", s.filePath, err)
-		return err
-	}
-	return nil
+// MarkPending 按 id 标记为解析中，保留上一次的结果直到新结果写入
+func (s *Store) MarkPending(id string) bool {
+	return s.update(id, func(it *Item) {
+		it.Status = StatusPending
+		it.Error = ""
+	})
 }
 
-// List 返回所有历史，新在前，最多 maxItems
-func (s *Store) List() []Item {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	out := make([]Item, 0, len(s.items))
-	for _, it := range s.items {
-		out = append(out, *it)
-	}
-	sort.Slice(out, func(i, j int) bool { return out[i].At > out[j].At })
-	if len(out) > maxItems {
-		out = out[:maxItems]
-	}
-	return out
+// SetFailed 按 id 记录解析失败，保留上一次成功的结果
+func (s *Store) SetFailed(id string, errMsg string) bool {
+	return s.update(id, func(it *Item) {
+		it.Status = StatusFailed
+		it.Error = errMsg
+	})
 }
 
-// Add 新增一条，返回 id
-func (s *Store) Add(it Item) string {
-	if it.ID == "" {
-		it.ID = uuid.New().String()
-	}
+// update 按 id 修改条目并重建检索索引、更新修改时间
+func (s *Store) update(id string, fn func(it *Item)) bool {
 	s.mu.Lock()
-	s.items = append(s.items, &it)
-	if len(s.items) > maxItems {
-		sort.Slice(s.items, func(i, j int) bool { return s.items[i].At > s.items[j].At })
-		s.items = s.items[:maxItems]
+	it, ok := s.idx.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return false
 	}
+	fn(it)
+	it.UpdatedAt = time.Now().UnixMilli()
+	s.idx.reindex(it)
 	s.mu.Unlock()
-	if err := s.save(); err != nil {
-		log.Printf("[history] save after Add: %v", err)
-	}
-	return it.ID
+	s.changed()
+	return true
 }
 
-// UpdateResult 按 id 更新解析结果
-func (s *Store) UpdateResult(id string, result *Result, taskID string) bool {
+// SetTags 按 id 设置标签（去重、去空白）
+func (s *Store) SetTags(id string, tags []string) bool {
 	s.mu.Lock()
-	for _, it := range s.items {
-		if it.ID == id {
-			it.Result = result
-			it.TaskID = taskID
-			s.mu.Unlock()
-			if err := s.save(); err != nil {
-				log.Printf("[history] save after UpdateResult: %v", err)
-			}
-			return true
-		}
+	it, ok := s.idx.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return false
 	}
+	it.Tags = normalizeTags(tags)
+	it.UpdatedAt = time.Now().UnixMilli()
 	s.mu.Unlock()
-	return false
+	s.changed()
+	return true
 }
 
-// Delete 按 id 删除一条历史
-func (s *Store) Delete(id string) bool {
+// SetPinned 按 id 置顶或取消置顶
+func (s *Store) SetPinned(id string, pinned bool) bool {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	for i, it := range s.items {
-		if it != nil && it.ID == id {
-			s.items = append(s.items[:i], s.items[i+1:]...)
-			if err := s.save(); err != nil {
-				log.Printf("[history] save after Delete: %v", err)
-			}
-			return true
-		}
+	it, ok := s.idx.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return false
 	}
-	return false
+	it.Pinned = pinned
+	it.UpdatedAt = time.Now().UnixMilli()
+	s.mu.Unlock()
+	s.changed()
+	return true
 }
 
-// FindLatestUploadByPath 返回同 path 最新一条（用于当前上传后解析时关联）
-func (s *Store) FindLatestUploadByPath(path string) *Item {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var found *Item
-	for _, it := range s.items {
-		if it.Type == "upload" && it.Path == path {
-			if found == nil || it.At > found.At {
-				found = it
-			}
-		}
-	}
-	if found == nil {
-		return nil
+// Delete 按 id 删除一条历史
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	ok := s.idx.remove(id)
+	s.mu.Unlock()
+	if ok {
+		s.changed()
 	}
-	cp := *found
-	return &cp
+	return ok
 }
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"time"
+
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/google/uuid"
+)
+
+// MaxVersions 每条历史最多保留的解析版本数，超出时丢弃最早的非当前版本
+const MaxVersions = 10
+
+// Version 一次解析的结果：重新生成、换模型或换 prompt 后各自成为一个版本
+type Version struct {
+	ID        string              `json:"id"`
+	Result    *explanation.Result `json:"result"`
+	TaskID    string              `json:"task_id,omitempty"`
+	Model     string              `json:"model,omitempty"` // 模型标识（provider|model|prompt 版本，非默认解析风格时附加 +风格）
+	CreatedAt int64               `json:"created_at"`      // 生成时间（毫秒）
+}
+
+// AddResult 按 id 追加一个解析版本并设为当前版本，状态置为 done，返回版本 ID；条目不存在时返回空。
+// 结果中的用户字段不随条目保存
+func (s *Store) AddResult(id string, result *explanation.Result, taskID, model string) string {
+	if result == nil {
+		return ""
+	}
+	result = result.Clone()
+	result.UserID = ""
+	v := Version{ID: uuid.New().String(), Result: result, TaskID: taskID, Model: model, CreatedAt: time.Now().UnixMilli()}
+	if !s.update(id, func(it *Item) {
+		it.Versions = append(it.Versions, v)
+		it.Preferred = v.ID
+		it.trimVersions()
+		it.applyPreferred()
+		it.Status = StatusDone
+		it.Error = ""
+	}) {
+		return ""
+	}
+	return v.ID
+}
+
+// SetPreferred 按 id 选择当前采用的解析版本；条目或版本不存在时返回 false
+func (s *Store) SetPreferred(id, versionID string) bool {
+	s.mu.Lock()
+	it, ok := s.idx.byID[id]
+	if !ok || it.Version(versionID) == nil {
+		s.mu.Unlock()
+		return false
+	}
+	it.Preferred = versionID
+	it.applyPreferred()
+	it.UpdatedAt = time.Now().UnixMilli()
+	s.idx.reindex(it)
+	s.mu.Unlock()
+	s.changed()
+	return true
+}
+
+// Version 按 ID 查找解析版本，不存在时返回 nil
+func (it *Item) Version(versionID string) *Version {
+	for i := range it.Versions {
+		if it.Versions[i].ID == versionID {
+			return &it.Versions[i]
+		}
+	}
+	return nil
+}
+
+// taskIDs 条目引用的全部解析任务 ID：当前版本与各历史版本
+func (it *Item) taskIDs() []string {
+	var ids []string
+	if it.TaskID != "" {
+		ids = append(ids, it.TaskID)
+	}
+	for _, v := range it.Versions {
+		if v.TaskID != "" && v.TaskID != it.TaskID {
+			ids = append(ids, v.TaskID)
+		}
+	}
+	return ids
+}
+
+// applyPreferred 将当前版本同步到 Result、TaskID、Model
+func (it *Item) applyPreferred() {
+	if v := it.Version(it.Preferred); v != nil {
+		it.Result, it.TaskID, it.Model = v.Result, v.TaskID, v.Model
+	}
+}
+
+// trimVersions 超出 MaxVersions 时丢弃最早的非当前版本；另建切片，不影响 Get 返回的副本
+func (it *Item) trimVersions() {
+	extra := len(it.Versions) - MaxVersions
+	if extra <= 0 {
+		return
+	}
+	kept := make([]Version, 0, MaxVersions)
+	for _, v := range it.Versions {
+		if extra > 0 && v.ID != it.Preferred {
+			extra--
+			continue
+		}
+		kept = append(kept, v)
+	}
+	it.Versions = kept
+}
+
+// normalizeVersions 新增条目时整理版本：去掉没有结果的版本，只带 Result 的条目作为第一个版本，当前版本无效时取最新版本
+func (it *Item) normalizeVersions() {
+	var valid []Version
+	for _, v := range it.Versions {
+		if v.Result != nil {
+			valid = append(valid, v)
+		}
+	}
+	it.Versions = valid
+	if len(it.Versions) == 0 {
+		if it.Result == nil {
+			return
+		}
+		it.Versions = []Version{{ID: uuid.New().String(), Result: it.Result, TaskID: it.TaskID, Model: it.Model, CreatedAt: it.UpdatedAt}}
+	}
+	if it.Version(it.Preferred) == nil {
+		it.Preferred = it.Versions[len(it.Versions)-1].ID
+	}
+	it.trimVersions()
+	it.applyPreferred()
+	if it.Status == "" {
+		it.Status = StatusDone
+	}
+}
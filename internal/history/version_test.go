@@ -0,0 +1,41 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+)
+
+func TestVersions(t *testing.T) {
+	s, _ := NewStore("", config.HistoryConfig{})
+	s.Add(Item{ID: "a", Type: "text", Text: "1+1"})
+	result := func(answer string) *explanation.Result {
+		return &explanation.Result{Answer: answer, UserID: "u1"}
+	}
+	first := s.AddResult("a", result("2"), "t1", "m1")
+	second := s.AddResult("a", result("3"), "t2", "m2")
+	it, _ := s.Get("a")
+	if len(it.Versions) != 2 || it.Preferred != second || it.Result.Answer != "3" || it.TaskID != "t2" || it.Result.UserID != "" {
+		t.Fatalf("after regenerate = %+v", it)
+	}
+	if !s.SetPreferred("a", first) || s.SetPreferred("a", "nope") {
+		t.Fatal("SetPreferred")
+	}
+	it, _ = s.Get("a")
+	if it.Result.Answer != "2" || it.Model != "m1" {
+		t.Errorf("preferred first = %+v", it)
+	}
+	// 超出上限时丢弃最早的版本，新生成的版本为当前版本
+	var last string
+	for i := 0; i < MaxVersions-1; i++ {
+		last = s.AddResult("a", result("x"), "", "")
+	}
+	it, _ = s.Get("a")
+	if len(it.Versions) != MaxVersions || it.Version(first) != nil || it.Version(second) == nil || it.Preferred != last {
+		t.Errorf("trimmed versions: %d, first kept %v", len(it.Versions), it.Version(first) != nil)
+	}
+	if s.AddResult("missing", result("1"), "", "") != "" {
+		t.Error("AddResult on missing item")
+	}
+}
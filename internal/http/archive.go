@@ -0,0 +1,405 @@
+package http
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+)
+
+// 历史归档格式：manifest.json + history.json + uploads/（题目图片）+ images/（步骤讲解图）
+const (
+	archiveVersion      = 1
+	archiveManifest     = "manifest.json"
+	archiveHistory      = "history.json"
+	archiveUploadsDir   = "uploads/"
+	archiveImagesDir    = "images/"
+	uploadURLPrefix     = "/api/uploads/"
+	maxImportMB         = 200  // 导入归档最大 MB
+	maxImportEntries    = 5000 // 归档内最多文件数
+	maxImportHistoryMB  = 50   // history.json 解压后最大 MB
+	importMemoryLimitMB = 32   // 解析 multipart 时内存中缓存的上限，超出部分写临时文件
+)
+
+// ArchiveManifest 归档说明
+type ArchiveManifest struct {
+	Version    int   `json:"version"`
+	ExportedAt int64 `json:"exported_at"`
+	Items      int   `json:"items"`
+}
+
+// HistoryImportResponse 导入结果
+type HistoryImportResponse struct {
+	Imported int `json:"imported"` // 新增的历史条数
+	Skipped  int `json:"skipped"`  // 与已有历史重复而跳过的条数
+	Images   int `json:"images"`   // 导入条目引用的图片数（含已存在而复用的）
+}
+
+// handleHistoryExport 导出调用者的全部历史为 zip：历史 JSON + 引用到的上传图片与本地讲解图
+func (s *Server) handleHistoryExport(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	items, err := s.allHistory(history.Query{UserID: caller(r.Context()).scope()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	files := make(map[string]string) // 归档内路径 → 上传目录中的文件名
+	for i := range items {
+		it := &items[i]
+		it.UserID = ""
+		it.TaskID = ""
+		if it.Type == "upload" && it.Path != "" {
+			files[archiveUploadsDir+it.Path] = it.Path
+		}
+		results := []*explanation.Result{it.Result}
+		// 查询返回的条目与存储共用 Versions 底层数组，清空任务 ID 前先复制
+		it.Versions = append([]history.Version(nil), it.Versions...)
+		for j := range it.Versions {
+			it.Versions[j].TaskID = ""
+			results = append(results, it.Versions[j].Result)
+		}
+		for _, res := range results {
+			if res == nil {
+				continue
+			}
+			for _, st := range res.Steps {
+				if name, ok := localUploadName(st.ImageURL); ok {
+					files[archiveImagesDir+name] = name
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gomath-history-%s.zip"`, time.Now().Format("20060102-150405")))
+	w.Header().Set("Cache-Control", "no-store")
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+	writeJSON := func(name string, v any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	if err := writeJSON(archiveManifest, ArchiveManifest{Version: archiveVersion, ExportedAt: time.Now().UnixMilli(), Items: len(items)}); err != nil {
+		log.Printf("[history] export: %v", err)
+		return
+	}
+	if err := writeJSON(archiveHistory, items); err != nil {
+		log.Printf("[history] export: %v", err)
+		return
+	}
+	for entry, name := range files {
+		absPath, ok := s.uploadPath(name)
+		if !ok {
+			continue
+		}
+		// 图片已被清理或不可访问时跳过，历史本身仍可导出
+		if err := copyFileToZip(zw, entry, absPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("[history] export %s: %v", name, err)
+			return
+		}
+	}
+}
+
+func copyFileToZip(zw *zip.Writer, entry, absPath string) error {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 图片已压缩，仅存储不再压缩
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// handleHistoryImport 导入 handleHistoryExport 生成的归档（multipart 字段 file）：图片按内容重新命名落盘，
+// 历史分配新 ID 归入调用者名下，与已有历史（类型、题目、图片、时间、题号均相同）重复的条目跳过
+func (s *Server) handleHistoryImport(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportMB<<20)
+	if err := r.ParseMultipartForm(importMemoryLimitMB << 20); err != nil {
+		http.Error(w, "archive too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing or invalid file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	zr, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		http.Error(w, "invalid zip archive", http.StatusBadRequest)
+		return
+	}
+	if len(zr.File) > maxImportEntries {
+		http.Error(w, "too many files in archive", http.StatusBadRequest)
+		return
+	}
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	var manifest ArchiveManifest
+	if err := readZipJSON(entries[archiveManifest], 1<<20, &manifest); err != nil {
+		http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if manifest.Version != archiveVersion {
+		http.Error(w, fmt.Sprintf("unsupported archive version %d", manifest.Version), http.StatusBadRequest)
+		return
+	}
+	var items []history.Item
+	if err := readZipJSON(entries[archiveHistory], maxImportHistoryMB<<20, &items); err != nil {
+		http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	owner := caller(r.Context()).UserID
+	var resp HistoryImportResponse
+	// 归档内文件名 → 落盘后的文件名（按内容哈希计算）；同一图片只校验一次
+	renamed := make(map[string]string)
+	checkFile := func(entry string) (string, error) {
+		if name, ok := renamed[entry]; ok {
+			return name, nil
+		}
+		f := entries[entry]
+		if f == nil {
+			return "", errArchiveFileMissing
+		}
+		data, err := readZipFile(f, int64(s.MaxSizeMB)<<20)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", entry, err)
+		}
+		_, name, err := s.checkUpload(path.Base(entry), data)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", entry, err)
+		}
+		renamed[entry] = name
+		return name, nil
+	}
+	// 待导入条目引用的图片，全部条目校验通过后才落盘
+	var files []string
+	used := make(map[string]bool)
+	useFile := func(entry string) {
+		if !used[entry] {
+			used[entry] = true
+			files = append(files, entry)
+		}
+	}
+
+	// importResult 校验解析结果中引用的本地讲解图并改为落盘后的文件名，归档中缺失的图片置空
+	importResult := func(res *explanation.Result, entries *[]string) (*explanation.Result, error) {
+		if res == nil {
+			return nil, nil
+		}
+		out := res.Clone()
+		out.UserID = ""
+		for i := range out.Steps {
+			name, ok := localUploadName(out.Steps[i].ImageURL)
+			if !ok {
+				continue
+			}
+			newName, err := checkFile(archiveImagesDir + name)
+			switch {
+			case errors.Is(err, errArchiveFileMissing):
+				out.Steps[i].ImageURL = ""
+			case err != nil:
+				return nil, err
+			default:
+				out.Steps[i].ImageURL = uploadURLPrefix + newName
+				*entries = append(*entries, archiveImagesDir+name)
+			}
+		}
+		return out, nil
+	}
+
+	existing, err := s.allHistory(history.Query{UserID: caller(r.Context()).UserID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[string]bool, len(existing))
+	for i := range existing {
+		seen[historyFingerprint(&existing[i])] = true
+	}
+	// 先校验全部条目与图片，任一不合格则整体不导入，不写入任何文件
+	var toAdd []history.Item
+	for _, it := range items {
+		if it.Type != "upload" && it.Type != "text" {
+			http.Error(w, "invalid archive: unknown item type "+it.Type, http.StatusBadRequest)
+			return
+		}
+		var itemFiles []string
+		if it.Type == "upload" && it.Path != "" {
+			name, err := checkFile(archiveUploadsDir + it.Path)
+			switch {
+			case errors.Is(err, errArchiveFileMissing):
+				// 导出时图片已被清理：保留题目文字与解析
+				it.Path = ""
+			case err != nil:
+				http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+				return
+			default:
+				itemFiles = append(itemFiles, archiveUploadsDir+it.Path)
+				it.Path = name
+			}
+		}
+		// 有版本时当前结果在新增时按当前版本重建，只需处理各版本
+		var err error
+		if len(it.Versions) == 0 {
+			it.Result, err = importResult(it.Result, &itemFiles)
+		}
+		for j := range it.Versions {
+			if err != nil {
+				break
+			}
+			it.Versions[j].TaskID = ""
+			it.Versions[j].Result, err = importResult(it.Versions[j].Result, &itemFiles)
+		}
+		if err != nil {
+			http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if it.Region != nil {
+			if err := it.Region.Validate(); err != nil {
+				it.Region = nil
+			}
+		}
+		fp := historyFingerprint(&it)
+		if seen[fp] {
+			resp.Skipped++
+			continue
+		}
+		seen[fp] = true
+		for _, entry := range itemFiles {
+			useFile(entry)
+		}
+		it.ID = ""
+		it.UserID = owner
+		it.TaskID = ""
+		switch {
+		case it.Status == history.StatusPending:
+			// 导出时仍在解析中，不会再有结果写回
+			it.Status = history.StatusFailed
+		case it.Status == "" && it.Result != nil:
+			// 早期归档不带解析状态
+			it.Status = history.StatusDone
+		}
+		toAdd = append(toAdd, it)
+	}
+	for _, entry := range files {
+		data, err := readZipFile(entries[entry], int64(s.MaxSizeMB)<<20)
+		if err == nil {
+			_, err = s.storeUpload(owner, path.Base(entry), data)
+		}
+		if err != nil {
+			log.Printf("[history] import %s: %v", entry, err)
+			http.Error(w, "failed to save image", http.StatusInternalServerError)
+			return
+		}
+	}
+	resp.Images = len(files)
+	for _, it := range toAdd {
+		s.HistoryStore.Add(it)
+	}
+	resp.Imported = len(toAdd)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// allHistory 分页取出符合 q 的全部历史（q.UserID 为空时为所有用户）
+func (s *Server) allHistory(q history.Query) ([]history.Item, error) {
+	var out []history.Item
+	q.Limit = history.MaxLimit
+	for {
+		page, err := s.HistoryStore.Search(q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Items...)
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// historyFingerprint 判断导入条目是否与已有历史重复的依据
+func historyFingerprint(it *history.Item) string {
+	return strings.Join([]string{it.Type, it.Text, it.Path, it.ProblemNo, fmt.Sprint(it.At)}, "\x00")
+}
+
+// localUploadName 讲解图 URL 指向本服务上传目录时返回文件名
+func localUploadName(url string) (string, bool) {
+	name, ok := strings.CutPrefix(url, uploadURLPrefix)
+	if !ok || name == "" || strings.ContainsAny(name, "/?#") || strings.Contains(name, "..") {
+		return "", false
+	}
+	return name, true
+}
+
+var (
+	errZipEntryTooLarge   = errors.New("file too large")
+	errArchiveFileMissing = errors.New("file missing in archive")
+)
+
+// readZipFile 读取归档内的文件，解压后超过 limit 字节时报错（防压缩炸弹）
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, errZipEntryTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errZipEntryTooLarge
+	}
+	return data, nil
+}
+
+func readZipJSON(f *zip.File, limit int64, v any) error {
+	if f == nil {
+		return errors.New("missing " + archiveManifest + " or " + archiveHistory)
+	}
+	data, err := readZipFile(f, limit)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return nil
+}
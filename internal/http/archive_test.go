@@ -0,0 +1,109 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+)
+
+// testPNG 返回一张可通过上传校验的小图
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// importRequest 将 files（归档内路径 → 内容）与历史条目打包为导入请求
+func importRequest(t *testing.T, items []history.Item, files map[string][]byte) *http.Request {
+	t.Helper()
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	add := func(name string, data []byte) {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	manifest, _ := json.Marshal(ArchiveManifest{Version: archiveVersion, Items: len(items)})
+	add(archiveManifest, manifest)
+	hist, _ := json.Marshal(items)
+	add(archiveHistory, hist)
+	for name, data := range files {
+		add(name, data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "history.zip")
+	fw.Write(archive.Bytes())
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/history/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestHistoryImportValidatesBeforeWriting(t *testing.T) {
+	s, hist := newTestServer(t, &stubExplainer{})
+	items := []history.Item{
+		{Type: "upload", Path: "good.png", At: 1},
+		{Type: "upload", Path: "bad.png", At: 2},
+	}
+	files := map[string][]byte{
+		archiveUploadsDir + "good.png": testPNG(t),
+		archiveUploadsDir + "bad.png":  []byte("not an image"),
+	}
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, importRequest(t, items, files))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rr.Code, rr.Body)
+	}
+	if names, _ := os.ReadDir(s.UploadDir); len(names) != 0 {
+		t.Errorf("upload dir has %d files after rejected import, want none", len(names))
+	}
+	if page, _ := hist.Search(history.Query{}); len(page.Items) != 0 {
+		t.Errorf("history has %d items after rejected import, want none", len(page.Items))
+	}
+
+	delete(files, archiveUploadsDir+"bad.png")
+	rr = httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, importRequest(t, items, files))
+	var resp HistoryImportResponse
+	if rr.Code != http.StatusOK || json.NewDecoder(rr.Body).Decode(&resp) != nil {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+	if resp.Imported != 2 || resp.Images != 1 {
+		t.Errorf("import = %+v, want 2 items and 1 image", resp)
+	}
+}
+
+func TestHistoryExportKeepsStoredTaskIDs(t *testing.T) {
+	s, hist := newTestServer(t, &stubExplainer{})
+	id := hist.Add(history.Item{Type: "text", Text: "x + 1 = 2"})
+	hist.AddResult(id, &explanation.Result{Answer: "x = 1"}, "task-1", "")
+
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/history/export", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body)
+	}
+	it, _ := hist.Get(id)
+	if it.TaskID != "task-1" || len(it.Versions) != 1 || it.Versions[0].TaskID != "task-1" {
+		t.Errorf("after export: task %q, versions %+v; want task-1 kept", it.TaskID, it.Versions)
+	}
+}
@@ -0,0 +1,282 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/auth"
+)
+
+// Authenticator 用户、会话与 API Key 管理
+type Authenticator interface {
+	Login(username, password string) (string, *auth.User, error)
+	Logout(token string)
+	Authenticate(token string) (*auth.User, bool)
+	CreateUser(username, password string, admin bool) (*auth.User, error)
+	CreateAPIKey(userID, name string) (string, *auth.APIKey, error)
+	ListAPIKeys(userID string) []auth.APIKey
+	DeleteAPIKey(userID, id string) bool
+}
+
+// sessionCookie 登录会话 Cookie 名
+const sessionCookie = "gomath_session"
+
+// principal 当前请求的调用者；未开启鉴权时为不带用户 ID 的管理员，可见全部数据
+type principal struct {
+	UserID string
+	Admin  bool
+}
+
+type principalKey struct{}
+
+// caller 返回 context 中的调用者
+func caller(ctx context.Context) principal {
+	if p, ok := ctx.Value(principalKey{}).(principal); ok {
+		return p
+	}
+	return principal{Admin: true}
+}
+
+// canAccess 调用者是否可访问属于 owner 的数据：管理员可访问全部，其余用户只能访问自己的
+func (p principal) canAccess(owner string) bool {
+	return p.Admin || owner == p.UserID
+}
+
+// scope 列表查询的用户过滤条件：管理员为空（不过滤）
+func (p principal) scope() string {
+	if p.Admin {
+		return ""
+	}
+	return p.UserID
+}
+
+// UserResponse 用户信息（不含密码哈希）
+type UserResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse 登录成功：会话令牌同时写入 HttpOnly Cookie，非浏览器客户端可用 Authorization: Bearer 携带
+type LoginResponse struct {
+	Token string       `json:"token"`
+	User  UserResponse `json:"user"`
+}
+
+// CreateUserRequest 管理员创建用户
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Admin    bool   `json:"admin"`
+}
+
+// APIKeyCreateRequest 创建 API Key
+type APIKeyCreateRequest struct {
+	Name string `json:"name"`
+}
+
+// APIKeyResponse API Key 信息；key 为明文，仅创建时返回一次
+type APIKeyResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"`
+	CreatedAt int64  `json:"created_at"`
+	Key       string `json:"key,omitempty"`
+}
+
+// APIKeyListResponse API Key 列表
+type APIKeyListResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+func toUserResponse(u *auth.User) UserResponse {
+	return UserResponse{ID: u.ID, Username: u.Username, Admin: u.Admin}
+}
+
+func toAPIKeyResponse(k *auth.APIKey) APIKeyResponse {
+	return APIKeyResponse{ID: k.ID, Name: k.Name, Prefix: k.Prefix, CreatedAt: k.CreatedAt}
+}
+
+// requestToken 取请求携带的令牌：X-API-Key、Authorization: Bearer 或会话 Cookie
+func requestToken(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// authenticate 鉴权中间件：开启鉴权时要求有效的会话或 API Key，并把调用者放入 context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, ok := s.Auth.Authenticate(requestToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gomath"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalKey{}, principal{UserID: u.ID, Admin: u.Admin})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	token, u, err := s.Auth.Login(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(LoginResponse{Token: token, User: toUserResponse(u)})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if token := requestToken(r); token != "" {
+		s.Auth.Logout(token)
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	u, ok := s.Auth.Authenticate(requestToken(r))
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(toUserResponse(u))
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !caller(r.Context()).Admin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	u, err := s.Auth.CreateUser(req.Username, req.Password, req.Admin)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, auth.ErrInvalidUsername), errors.Is(err, auth.ErrWeakPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toUserResponse(u))
+}
+
+func (s *Server) handleAPIKeyCreate(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req APIKeyCreateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	secret, k, err := s.Auth.CreateAPIKey(caller(r.Context()).UserID, strings.TrimSpace(req.Name))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := toAPIKeyResponse(k)
+	resp.Key = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleAPIKeyList(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	keys := s.Auth.ListAPIKeys(caller(r.Context()).UserID)
+	out := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		out = append(out, toAPIKeyResponse(&keys[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(APIKeyListResponse{Keys: out})
+}
+
+func (s *Server) handleAPIKeyDelete(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.Auth.DeleteAPIKey(caller(r.Context()).UserID, chi.URLParam(r, "id")) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gomath/gomath/internal/cache"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/media"
+)
+
+// ResultCache 模型结果缓存：键 → 任意可 JSON 序列化的值
+type ResultCache interface {
+	Get(key string, v any) bool
+	Put(key string, v any)
+	Stats() cache.Stats
+}
+
+// fingerprinter 模型标识（provider、model、prompt 版本），实现该接口的识图/解析能力才会被缓存
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// imageKey 按送入模型的图片内容 + 模型标识生成缓存/合并键；模型未提供标识或读图失败时返回空
+func imageKey(kind string, model any, imagePath string) string {
+	fp, ok := model.(fingerprinter)
+	if !ok {
+		return ""
+	}
+	hash, err := media.HashFile(imagePath)
+	if err != nil {
+		log.Printf("[cache] hash %s: %v", imagePath, err)
+		return ""
+	}
+	return cache.Key(kind, hash, fp.Fingerprint())
+}
+
+// textKey 按规范化后的题目文本 + 模型标识生成缓存/合并键（教材原题重复率高）
+func textKey(kind string, model any, problemText string) string {
+	fp, ok := model.(fingerprinter)
+	if !ok {
+		return ""
+	}
+	return cache.Key(kind, explanation.NormalizeProblem(problemText), fp.Fingerprint())
+}
+
+// styledKey 非默认解析风格的结果与默认风格分开缓存、分开合并
+func styledKey(ctx context.Context, key string) string {
+	if style := explanation.StyleFrom(ctx); key != "" && style != "" {
+		return key + "|" + style
+	}
+	return key
+}
+
+// recognizeCached 识图，同一图片（预处理后内容相同）+ 同一模型与 prompt 版本直接返回缓存文本
+func (s *Server) recognizeCached(ctx context.Context, imagePath string) (text string, cached bool, err error) {
+	var key string
+	if s.Cache != nil {
+		key = imageKey("ocr", s.OCR, imagePath)
+	}
+	if key != "" && s.Cache.Get(key, &text) {
+		return text, true, nil
+	}
+	text, err = s.OCR.Recognize(ctx, imagePath)
+	if err != nil {
+		return "", false, err
+	}
+	if key != "" {
+		s.Cache.Put(key, text)
+	}
+	return text, false, nil
+}
+
+// explainImageCached 看图解析，缓存规则同 recognizeCached；缓存的是模型输出，讲解图仍按次生成。
+// regenerate 为 true 时跳过缓存读取，重新生成并覆盖缓存。
+func (s *Server) explainImageCached(ctx context.Context, imagePath string, regenerate bool) (*explanation.Result, bool, error) {
+	key := styledKey(ctx, imageKey("explain-image", s.ExplainGen, imagePath))
+	return s.explainShared(ctx, key, regenerate, func(ctx context.Context) (*explanation.Result, error) {
+		return s.ExplainGen.GenerateFromImage(ctx, imagePath)
+	})
+}
+
+// explainTextCached 文本解析，按规范化后的题目文本 + 模型标识缓存；regenerate 同 explainImageCached
+func (s *Server) explainTextCached(ctx context.Context, problemText string, regenerate bool) (*explanation.Result, bool, error) {
+	key := styledKey(ctx, textKey("explain-text", s.ExplainGen, problemText))
+	return s.explainShared(ctx, key, regenerate, func(ctx context.Context) (*explanation.Result, error) {
+		return s.ExplainGen.Generate(ctx, problemText)
+	})
+}
+
+// explainShared 先查缓存；未命中时相同 key 的并发请求合并为一次上游调用，每个请求拿到独立副本（各自保存为新任务）。
+// 重新生成的请求只与重新生成的请求合并，不会拿到普通请求正在生成的结果。
+// 用量只记在发起上游调用的请求上，与命中缓存一样，加入合并的请求没有模型调用，结果不带用量。
+// key 为空时既不缓存也不合并。
+func (s *Server) explainShared(ctx context.Context, key string, regenerate bool, generate func(ctx context.Context) (*explanation.Result, error)) (*explanation.Result, bool, error) {
+	if key == "" {
+		result, err := generate(ctx)
+		return result, false, err
+	}
+	if s.Cache != nil && !regenerate {
+		var result explanation.Result
+		if s.Cache.Get(key, &result) {
+			return &result, true, nil
+		}
+	}
+	flightKey := key
+	if regenerate {
+		flightKey += "|regenerate"
+	}
+	v, shared, err := s.flights.do(ctx, flightKey, func(ctx context.Context) (any, error) {
+		result, err := generate(ctx)
+		if err == nil && s.Cache != nil {
+			s.Cache.Put(key, result)
+		}
+		return result, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if shared {
+		log.Printf("[explain] joined in-flight request %s", flightKey)
+	}
+	return v.(*explanation.Result).Clone(), false, nil
+}
+
+// handleCacheStats 缓存命中统计（全局数据，仅管理员可见）
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !caller(r.Context()).Admin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if s.Cache == nil {
+		http.Error(w, "cache not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.Cache.Stats())
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomath/gomath/internal/explanation"
+)
+
+func TestExplainSharedRegenerateNotJoined(t *testing.T) {
+	s := &Server{}
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	generate := func(context.Context) (*explanation.Result, error) {
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+		return &explanation.Result{Answer: "x = 1"}, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.explainShared(context.Background(), "k", false, generate)
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		s.explainShared(context.Background(), "k", true, generate)
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+	}
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("upstream calls = %d, want 2 (regenerate must not join a normal request)", n)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/usage"
 )
 
 // ExplainGenerator 生成分步解析（支持文本或图片直接解析）
@@ -23,22 +26,35 @@ type ExplainGenerator interface {
 type ExplainStore interface {
 	Put(r *explanation.Result) string
 	Get(id string) (*explanation.Result, bool)
+	Delete(id string)
 }
 
-// ExplainRequest 请求生成解析：problem_text 与 image_path 二选一；传 image_path 时直接让模型看图解析
+// ExplainRequest 请求生成解析：problem_text 与 image_path 二选一；传 image_path 时直接让模型看图解析。
+// 传 history_id 时结果记录到该条历史，此时两者都不传则解析该条历史的题目，传入时须与该条的题目文字或图片一致；
+// 不传时自动新建一条历史
 type ExplainRequest struct {
-	ProblemText string `json:"problem_text"`
-	ImagePath   string `json:"image_path"` // 已上传图片路径（相对 upload 目录），与 problem_text 二选一
+	HistoryID   string        `json:"history_id,omitempty"`
+	ProblemText string        `json:"problem_text"`
+	ImagePath   string        `json:"image_path"`       // 已上传图片路径（相对 upload 目录），与 problem_text 二选一
+	Region      *media.Region `json:"region,omitempty"` // 可选：只解析图片中的某个区域（裁剪 + 旋转）
+	Regenerate  bool          `json:"regenerate"`       // 忽略缓存重新生成（如对缓存结果不满意）
+	Style       string        `json:"style,omitempty"`  // 解析风格：concise（简洁）、detailed（详细），为空时默认风格
 }
 
 // ExplainResponse 返回任务 ID，前端可轮询 GET /api/result/:id
 type ExplainResponse struct {
-	TaskID string `json:"task_id"`
+	TaskID    string `json:"task_id"`
+	HistoryID string `json:"history_id,omitempty"` // 记录结果的历史条目，未配置历史时为空
+	VersionID string `json:"version_id,omitempty"` // 本次结果在历史条目中的解析版本
+	Cached    bool   `json:"cached,omitempty"`     // 解析结果来自缓存（同一图片或同一题目此前已解析过）
 }
 
 // ResultResponse 解析结果（步骤列表 + 每步文字与配图 URL）
 type ResultResponse struct {
-	Steps []StepResponse `json:"steps"`
+	Problem string         `json:"problem,omitempty"`
+	Steps   []StepResponse `json:"steps"`
+	Answer  string         `json:"answer,omitempty"`
+	Usage   *usage.Summary `json:"usage,omitempty"`
 }
 
 // StepResponse 单步
@@ -58,51 +74,194 @@ func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
+	s.serveExplain(w, r, req)
+}
+
+// serveExplain 按请求生成解析并返回任务 ID，结果记录到指定或新建的历史条目（新增一个解析版本）
+func (s *Server) serveExplain(w http.ResponseWriter, r *http.Request, req ExplainRequest) {
 	if req.ProblemText != "" && req.ImagePath != "" {
 		http.Error(w, "provide either problem_text or image_path, not both", http.StatusBadRequest)
 		return
 	}
-	if req.ProblemText == "" && req.ImagePath == "" {
-		http.Error(w, "problem_text or image_path required", http.StatusBadRequest)
+	if !explanation.ValidStyle(req.Style) {
+		http.Error(w, fmt.Sprintf("unknown style %q", req.Style), http.StatusBadRequest)
 		return
 	}
 	if s.ExplainGen == nil || s.ExplainStore == nil {
 		http.Error(w, "explanation not configured", http.StatusServiceUnavailable)
 		return
 	}
-	var result *explanation.Result
-	var err error
+	p := caller(r.Context())
+	if req.HistoryID != "" {
+		if s.HistoryStore == nil {
+			http.Error(w, "history not configured", http.StatusServiceUnavailable)
+			return
+		}
+		it, ok := s.HistoryStore.Get(req.HistoryID)
+		if !ok || !p.canAccess(it.UserID) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		// 结果记入该条历史，显式传入的题目必须就是该条的题目，否则历史中的题目与解析对不上
+		if req.ProblemText != "" && strings.TrimSpace(req.ProblemText) != strings.TrimSpace(it.Text) {
+			http.Error(w, "problem_text does not match history item", http.StatusBadRequest)
+			return
+		}
+		if req.ImagePath != "" && req.ImagePath != it.Path {
+			http.Error(w, "image_path does not match history item", http.StatusBadRequest)
+			return
+		}
+		if req.ProblemText == "" && req.ImagePath == "" {
+			// 与卷面一致：有题目文字时按文字解析（多题图片拆出的题只解析本题），否则看图
+			if strings.TrimSpace(it.Text) != "" {
+				req.ProblemText = it.Text
+			} else {
+				req.ImagePath, req.Region = it.Path, it.Region
+			}
+		}
+	}
+	if req.ProblemText == "" && req.ImagePath == "" {
+		http.Error(w, "problem_text or image_path required", http.StatusBadRequest)
+		return
+	}
+	if req.ProblemText != "" {
+		if err := s.checkProblemText(req.ProblemText); err != nil {
+			http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+			return
+		}
+	}
+	var absPath string
 	if req.ImagePath != "" {
-		absPath := filepath.Join(s.UploadDir, req.ImagePath)
-		result, err = s.ExplainGen.GenerateFromImage(r.Context(), absPath)
+		var err error
+		if absPath, err = s.modelImagePath(r.Context(), req.ImagePath, req.Region); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	historyID := req.HistoryID
+	if historyID == "" && s.HistoryStore != nil {
+		it := history.Item{UserID: p.UserID, Type: "text", Text: req.ProblemText}
+		if req.ImagePath != "" {
+			it = history.Item{UserID: p.UserID, Type: "upload", Path: req.ImagePath, Region: req.Region}
+		}
+		historyID = s.HistoryStore.Add(it)
+	}
+
+	ctx, rec := s.withUsage(r.Context(), "explain")
+	out, err := s.runExplain(ctx, rec, explainInput{
+		problemText: req.ProblemText,
+		imagePath:   req.ImagePath,
+		absPath:     absPath,
+		regenerate:  req.Regenerate,
+		style:       req.Style,
+		stepImages:  true,
+		historyID:   historyID,
+	})
+	if err != nil {
+		http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExplainResponse{TaskID: out.taskID, HistoryID: historyID, VersionID: out.versionID, Cached: out.cached})
+}
+
+// explainInput 一次解析的输入：题目文字，或看图解析的图片
+type explainInput struct {
+	problemText string
+	imagePath   string // 上传文件名（相对 upload 目录），记入结果
+	absPath     string // 送入模型的图片（modelImagePath 预处理后），非空时看图解析
+	regenerate  bool
+	style       string // 解析风格，空为默认风格
+	stepImages  bool   // 按步骤生成讲解图（配置了 ImageGen 时）
+	historyID   string // 非空时在该条历史上记录解析中、完成或失败
+}
+
+// explainOutput 一次解析的结果
+type explainOutput struct {
+	taskID    string
+	versionID string // 记录到历史条目的解析版本，未记录历史时为空
+	result    *explanation.Result
+	cached    bool
+}
+
+// runExplain 生成解析并保存为任务，用量记入 ctx 上的 rec；结果归属调用者
+func (s *Server) runExplain(ctx context.Context, rec *usage.Recorder, in explainInput) (*explainOutput, error) {
+	if in.historyID != "" {
+		s.HistoryStore.MarkPending(in.historyID)
+	}
+	if in.style != "" {
+		ctx = explanation.WithStyle(ctx, in.style)
+	}
+	var out explainOutput
+	var err error
+	if in.absPath != "" {
+		out.result, out.cached, err = s.explainImageCached(ctx, in.absPath, in.regenerate)
 	} else {
-		result, err = s.ExplainGen.Generate(r.Context(), req.ProblemText)
+		out.result, out.cached, err = s.explainTextCached(ctx, in.problemText, in.regenerate)
 	}
 	if err != nil {
 		log.Printf("[explain] error: %v", err)
-		msg := err.Error()
-		if errors.Is(err, context.DeadlineExceeded) {
-			msg = "解析超时，请稍后重试或调大 config 中 llm.explanation.timeout_sec"
-		} else if strings.Contains(msg, "504") {
-			msg = "上游模型/网关返回 504 超时（约 60 秒），请检查网关超时配置或稍后重试"
+		if in.historyID != "" {
+			s.HistoryStore.SetFailed(in.historyID, explainErrorMessage(err))
 		}
-		http.Error(w, msg, http.StatusInternalServerError)
+		return nil, err
+	}
+	result := out.result
+	result.Usage = rec.Summary()
+	result.UserID = caller(ctx).UserID
+	result.ImagePath = in.imagePath
+	if in.stepImages {
+		s.generateStepImages(ctx, result)
+	}
+	out.taskID = s.ExplainStore.Put(result)
+	if in.historyID != "" {
+		out.versionID = s.HistoryStore.AddResult(in.historyID, result, out.taskID, explanation.StyledFingerprint(s.explainModel(), in.style))
+	}
+	return &out, nil
+}
+
+// explainModel 解析模型标识（provider|model|prompt 版本），生成器未提供时为空
+func (s *Server) explainModel() string {
+	if fp, ok := s.ExplainGen.(fingerprinter); ok {
+		return fp.Fingerprint()
+	}
+	return ""
+}
+
+// generateStepImages 若配置了讲解图生成，按步骤生成并绑定 URL
+func (s *Server) generateStepImages(ctx context.Context, result *explanation.Result) {
+	if s.ImageGen == nil {
 		return
 	}
-	// 若配置了讲解图生成，按步骤生成并绑定 URL
-	if s.ImageGen != nil {
-		for i := range result.Steps {
-			prompt := result.Steps[i].ImagePrompt
-			if prompt == "" {
-				prompt = result.Steps[i].Title + ": " + result.Steps[i].Content
-			}
-			url, _ := s.ImageGen.Generate(r.Context(), prompt)
-			result.Steps[i].ImageURL = url
+	for i := range result.Steps {
+		prompt := result.Steps[i].ImagePrompt
+		if prompt == "" {
+			prompt = result.Steps[i].Title + ": " + result.Steps[i].Content
 		}
+		url, _ := s.ImageGen.Generate(ctx, prompt)
+		result.Steps[i].ImageURL = url
 	}
-	taskID := s.ExplainStore.Put(result)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ExplainResponse{TaskID: taskID})
+}
+
+// explainErrorMessage 将模型调用错误转为面向用户的提示（超时类错误给出排查建议）
+func explainErrorMessage(err error) string {
+	msg := err.Error()
+	if errors.Is(err, explanation.ErrInputTooLong) {
+		msg = "题目过长，请只保留题目本身后重试（" + msg + "）"
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		msg = "解析超时，请稍后重试或调大 config 中 llm.explanation.timeout_sec"
+	} else if strings.Contains(msg, "504") {
+		msg = "上游模型/网关返回 504 超时（约 60 秒），请检查网关超时配置或稍后重试"
+	}
+	return msg
+}
+
+// explainErrorStatus 模型调用错误对应的 HTTP 状态码：输入超长为 413，其余为 500
+func explainErrorStatus(err error) int {
+	if errors.Is(err, explanation.ErrInputTooLong) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
 }
 
 func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
@@ -116,10 +275,16 @@ func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	result, ok := s.ExplainStore.Get(taskID)
-	if !ok {
+	if !ok || !caller(r.Context()).canAccess(result.UserID) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toResultResponse(result))
+}
+
+// toResultResponse 解析结果转为接口返回格式
+func toResultResponse(result *explanation.Result) ResultResponse {
 	steps := make([]StepResponse, 0, len(result.Steps))
 	for _, st := range result.Steps {
 		steps = append(steps, StepResponse{
@@ -128,6 +293,5 @@ func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
 			ImageURL: st.ImageURL,
 		})
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ResultResponse{Steps: steps})
+	return ResultResponse{Problem: result.Problem, Steps: steps, Answer: result.Answer, Usage: result.Usage}
 }
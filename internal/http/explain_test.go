@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+)
+
+// stubExplainer 文字解析返回固定结果，看图解析返回 imageErr
+type stubExplainer struct {
+	imageErr error
+}
+
+func (g *stubExplainer) Generate(_ context.Context, problemText string) (*explanation.Result, error) {
+	return &explanation.Result{Problem: problemText, Answer: "x = 1", Steps: []explanation.StepResult{{Title: "移项", Content: "x = 1"}}}, nil
+}
+
+func (g *stubExplainer) GenerateFromImage(context.Context, string) (*explanation.Result, error) {
+	if g.imageErr != nil {
+		return nil, g.imageErr
+	}
+	return &explanation.Result{Answer: "x = 1"}, nil
+}
+
+func newTestServer(t *testing.T, gen ExplainGenerator) (*Server, *history.Store) {
+	t.Helper()
+	hist, err := history.NewStore("", config.HistoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(t.TempDir(), 10, nil, gen, explanation.NewStore(), nil, hist), hist
+}
+
+func TestExplainImageError(t *testing.T) {
+	s, hist := newTestServer(t, &stubExplainer{imageErr: errors.New("vision api: boom")})
+	if err := os.WriteFile(filepath.Join(s.UploadDir, "a.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/explain", strings.NewReader(`{"image_path":"a.png"}`)))
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "boom") {
+		t.Fatalf("status = %d, body = %q, want 500 with the explainer error", rr.Code, rr.Body)
+	}
+	page, err := hist.Search(history.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Status != history.StatusFailed {
+		t.Fatalf("history = %+v, want one failed item", page.Items)
+	}
+}
+
+func TestExplainHistoryMismatch(t *testing.T) {
+	s, hist := newTestServer(t, &stubExplainer{})
+	id := hist.Add(history.Item{Type: "text", Text: "2x + 4 = 10"})
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"history_id":"` + id + `","problem_text":"x + 1 = 2"}`, http.StatusBadRequest},
+		{`{"history_id":"` + id + `","image_path":"a.png"}`, http.StatusBadRequest},
+		{`{"history_id":"` + id + `","problem_text":" 2x + 4 = 10 "}`, http.StatusOK},
+		{`{"history_id":"` + id + `"}`, http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/explain", strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d: %s", tc.body, rr.Code, tc.want, rr.Body)
+		}
+	}
+	if it, _ := hist.Get(id); it.Text != "2x + 4 = 10" || len(it.Versions) != 2 {
+		t.Errorf("history item = %+v, want original text with 2 versions", it)
+	}
+}
+
+func TestExplainInputTooLong(t *testing.T) {
+	// 题目文本超出 max_input_tokens 时在调用模型前拒绝
+	s, _ := newTestServer(t, explanation.NewGenerator(config.LLMExplanationConfig{MaxInputTokens: 10}))
+	body := `{"problem_text":"` + strings.Repeat("解方程 2x + 4 = 10，", 50) + `"}`
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/explain", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413: %s", rr.Code, rr.Body)
+	}
+	if got := explainErrorStatus(errors.New("upstream")); got != http.StatusInternalServerError {
+		t.Errorf("other error status = %d, want 500", got)
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/export"
+	"github.com/gomath/gomath/internal/media"
+)
+
+// exportFormat 导出格式：渲染函数、Content-Type、是否内嵌本地图片
+type exportFormat struct {
+	render      func(*export.Document) []byte
+	contentType string
+	embed       bool // 本地图片内嵌为 data URI；否则给出绝对链接
+}
+
+var exportFormats = map[string]exportFormat{
+	"md":   {export.Markdown, "text/markdown; charset=utf-8", true},
+	"tex":  {export.LaTeX, "application/x-tex; charset=utf-8", false},
+	"html": {export.HTML, "text/html; charset=utf-8", true},
+}
+
+// handleResultExport 将解析结果导出为独立文档：GET /result/{id}/export?format=md|tex|html（默认 md）
+func (s *Server) handleResultExport(w http.ResponseWriter, r *http.Request) {
+	if s.ExplainStore == nil {
+		http.Error(w, "not configured", http.StatusServiceUnavailable)
+		return
+	}
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "md"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, "format must be md, tex or html", http.StatusBadRequest)
+		return
+	}
+	taskID := chi.URLParam(r, "id")
+	result, ok := s.ExplainStore.Get(taskID)
+	if !ok || !caller(r.Context()).canAccess(result.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	doc := s.exportDocument(r, result, format.embed)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="result-%s.%s"`, taskID, name))
+	w.Write(format.render(doc))
+}
+
+// exportDocument 解析结果转为导出文档，题目图片与本服务生成的讲解图按 embed 内嵌或转为绝对链接
+func (s *Server) exportDocument(r *http.Request, result *explanation.Result, embed bool) *export.Document {
+	doc := &export.Document{Title: "题目解析", Problem: result.Problem, Answer: result.Answer}
+	if result.ImagePath != "" {
+		doc.ProblemImage = s.exportImage(r, result.ImagePath, embed)
+	}
+	for _, st := range result.Steps {
+		img := st.ImageURL
+		if name, ok := localUploadName(img); ok {
+			img = s.exportImage(r, name, embed)
+		}
+		doc.Steps = append(doc.Steps, export.Step{Title: st.Title, Content: st.Content, Image: img})
+	}
+	return doc
+}
+
+// exportImage 上传目录中的图片：embed 时读为 data URI（文件已不存在时省略），否则为带域名的链接
+func (s *Server) exportImage(r *http.Request, name string, embed bool) string {
+	absPath, ok := s.uploadPath(name)
+	if !ok {
+		return ""
+	}
+	if !embed {
+		return s.requestOrigin(r) + uploadURLPrefix + name
+	}
+	return s.exportFileImage(absPath)
+}
+
+// exportFileImage 读取图片为 data URI，读取失败时返回空
+func (s *Server) exportFileImage(absPath string) string {
+	data, mime, err := media.ReadBase64(absPath)
+	if err != nil {
+		return ""
+	}
+	return "data:" + mime + ";base64," + data
+}
+
+// requestOrigin 请求的协议 + 域名；仅在 TrustProxy 时以 X-Forwarded-Proto / X-Forwarded-Host 为准，避免客户端伪造导出文件中的链接
+func (s *Server) requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if !s.TrustProxy {
+		return scheme + "://" + host
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+		host = strings.TrimSpace(strings.Split(h, ",")[0])
+	}
+	return scheme + "://" + host
+}
@@ -0,0 +1,22 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestOriginForwardedHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://gomath.local/api/result/1/export", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "evil.example, proxy.local")
+
+	s := &Server{}
+	if got := s.requestOrigin(r); got != "http://gomath.local" {
+		t.Errorf("untrusted origin = %q, want http://gomath.local", got)
+	}
+	s.TrustProxy = true
+	if got := s.requestOrigin(r); got != "https://evil.example" {
+		t.Errorf("trusted origin = %q, want https://evil.example", got)
+	}
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"sync"
+)
+
+// flightCall 一次进行中的上游调用
+type flightCall struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// flightGroup 合并相同 key 的并发上游调用：同一时刻只发起一次，其余请求等待并共享结果。
+// 上游调用不随任一请求取消（使用 context.WithoutCancel），每个等待方只受自己的 ctx 控制，
+// 某个客户端断开不会影响其他同学的请求。fn 的用量记入发起方 ctx 上的记录器，等待方不重复计量。零值可用。
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do 执行或加入 key 对应的调用；shared 表示结果来自其他请求发起的调用
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (val any, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &flightCall{done: make(chan struct{})}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn(context.WithoutCancel(ctx))
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+	select {
+	case <-c.done:
+		return c.val, ok, c.err
+	case <-ctx.Done():
+		return nil, ok, ctx.Err()
+	}
+}
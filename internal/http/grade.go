@@ -0,0 +1,153 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/ocr"
+	"github.com/gomath/gomath/internal/usage"
+)
+
+// SolutionTranscriber 学生作答识别：作答图片 → 步骤列表
+type SolutionTranscriber interface {
+	TranscribeSolution(ctx context.Context, imagePath string) ([]string, error)
+}
+
+// SolutionGrader 将学生作答与参考解析逐步比对
+type SolutionGrader interface {
+	Grade(ctx context.Context, problemText string, reference *explanation.Result, studentSteps []string) (*explanation.GradeResult, error)
+}
+
+// GradeRequest 批改请求：题目（problem_text / problem_image_path / task_id 三选一）+ 学生作答（answer_text / answer_image_path 二选一）。
+// 传 task_id 时直接使用已有解析作为参考，否则先生成参考解析。
+type GradeRequest struct {
+	ProblemText      string `json:"problem_text"`
+	ProblemImagePath string `json:"problem_image_path"` // 已上传题目图片路径（相对 upload 目录）
+	TaskID           string `json:"task_id"`            // 已有解析任务 ID
+	AnswerText       string `json:"answer_text"`        // 学生作答文本，每行一步
+	AnswerImagePath  string `json:"answer_image_path"`  // 已上传学生作答图片路径（相对 upload 目录）
+}
+
+// GradeResponse 批改结果，reference_task_id 为参考解析任务 ID，可通过 GET /api/result/:id 查看
+type GradeResponse struct {
+	ReferenceTaskID string         `json:"reference_task_id"`
+	Usage           *usage.Summary `json:"usage,omitempty"` // 本次批改（含识别作答、生成参考解析）的模型用量与费用
+	*explanation.GradeResult
+}
+
+func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req GradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	n := 0
+	for _, v := range []string{req.ProblemText, req.ProblemImagePath, req.TaskID} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		http.Error(w, "provide exactly one of problem_text, problem_image_path or task_id", http.StatusBadRequest)
+		return
+	}
+	if (req.AnswerText == "") == (req.AnswerImagePath == "") {
+		http.Error(w, "provide either answer_text or answer_image_path", http.StatusBadRequest)
+		return
+	}
+	if s.Grader == nil || s.ExplainStore == nil {
+		http.Error(w, "grading not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, rec := s.withUsage(r.Context(), "grade")
+	// 学生作答 → 步骤
+	var studentSteps []string
+	if req.AnswerImagePath != "" {
+		if s.Transcriber == nil {
+			http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
+			return
+		}
+		absPath, err := s.modelImagePath(r.Context(), req.AnswerImagePath, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		studentSteps, err = s.Transcriber.TranscribeSolution(ctx, absPath)
+		if err != nil {
+			// 上游识别失败，详情只记日志
+			log.Printf("[grade] transcribe error: %v", err)
+			http.Error(w, "failed to transcribe solution", http.StatusBadGateway)
+			return
+		}
+	} else {
+		studentSteps = ocr.SplitSteps(req.AnswerText)
+	}
+	if len(studentSteps) == 0 {
+		http.Error(w, "student solution is empty", http.StatusBadRequest)
+		return
+	}
+
+	// 参考解析：已有任务，或按题目生成
+	taskID := req.TaskID
+	var reference *explanation.Result
+	problemText := req.ProblemText
+	if taskID != "" {
+		var ok bool
+		reference, ok = s.ExplainStore.Get(taskID)
+		if !ok || !caller(r.Context()).canAccess(reference.UserID) {
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		}
+		problemText = reference.Problem
+	} else {
+		if s.ExplainGen == nil {
+			http.Error(w, "explanation not configured", http.StatusServiceUnavailable)
+			return
+		}
+		var err error
+		if req.ProblemImagePath != "" {
+			var absPath string
+			absPath, err = s.modelImagePath(r.Context(), req.ProblemImagePath, nil)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if s.OCR != nil {
+				// 题目文字仅用于辅助批改，识别失败不影响流程
+				if text, _, err := s.recognizeCached(ctx, absPath); err == nil {
+					problemText = text
+				} else {
+					log.Printf("[grade] recognize problem: %v", err)
+				}
+			}
+			reference, err = s.ExplainGen.GenerateFromImage(ctx, absPath)
+		} else {
+			reference, err = s.ExplainGen.Generate(ctx, problemText)
+		}
+		if err != nil {
+			log.Printf("[grade] reference explanation error: %v", err)
+			http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+			return
+		}
+		reference.Usage = rec.Summary()
+		reference.UserID = caller(r.Context()).UserID
+		taskID = s.ExplainStore.Put(reference)
+	}
+
+	grade, err := s.Grader.Grade(ctx, problemText, reference, studentSteps)
+	if err != nil {
+		log.Printf("[grade] error: %v", err)
+		http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GradeResponse{ReferenceTaskID: taskID, Usage: rec.Summary(), GradeResult: grade})
+}
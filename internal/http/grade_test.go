@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomath/gomath/internal/explanation"
+)
+
+// stubGrader 记录收到的题目与作答，全部判对
+type stubGrader struct {
+	problemText string
+	steps       []string
+}
+
+func (g *stubGrader) Grade(_ context.Context, problemText string, _ *explanation.Result, studentSteps []string) (*explanation.GradeResult, error) {
+	g.problemText, g.steps = problemText, studentSteps
+	return &explanation.GradeResult{Score: 100}, nil
+}
+
+type stubTranscriber struct {
+	err error
+}
+
+func (t stubTranscriber) TranscribeSolution(context.Context, string) ([]string, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return []string{"2x = 6", "x = 3"}, nil
+}
+
+func TestGrade(t *testing.T) {
+	s, _ := newTestServer(t, &stubExplainer{})
+	grader := &stubGrader{}
+	s.Grader, s.OCR = grader, stubOCR{}
+	s.Transcriber = stubTranscriber{}
+	for _, name := range []string{"q.png", "a.png"} {
+		if err := os.WriteFile(filepath.Join(s.UploadDir, name), []byte("png"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	taskID := s.ExplainStore.Put(&explanation.Result{Problem: "2x = 6", Answer: "x = 3"})
+
+	for _, tc := range []struct {
+		name, body  string
+		want        int
+		problemText string // 传给 Grader 的题目
+		steps       int    // 传给 Grader 的作答步数
+	}{
+		{"text", `{"problem_text":"2x = 6","answer_text":"x = 6 / 2\nx = 3"}`, http.StatusOK, "2x = 6", 2},
+		{"problem image", `{"problem_image_path":"q.png","answer_text":"x = 3"}`, http.StatusOK, "2x + 4 = 10", 1},
+		{"task", `{"task_id":"` + taskID + `","answer_text":"x = 3"}`, http.StatusOK, "2x = 6", 1},
+		{"answer image", `{"problem_text":"2x = 6","answer_image_path":"a.png"}`, http.StatusOK, "2x = 6", 2},
+		{"missing task", `{"task_id":"nope","answer_text":"x = 3"}`, http.StatusNotFound, "", 0},
+		{"two problems", `{"problem_text":"2x = 6","task_id":"` + taskID + `","answer_text":"x = 3"}`, http.StatusBadRequest, "", 0},
+		{"no answer", `{"problem_text":"2x = 6"}`, http.StatusBadRequest, "", 0},
+	} {
+		*grader = stubGrader{}
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/grade", strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, rr.Code, tc.want, rr.Body)
+			continue
+		}
+		if grader.problemText != tc.problemText || len(grader.steps) != tc.steps {
+			t.Errorf("%s: graded problem %q with %d steps, want %q with %d", tc.name, grader.problemText, len(grader.steps), tc.problemText, tc.steps)
+		}
+	}
+
+	// 上游识别作答失败为 502，不向客户端透出上游错误
+	s.Transcriber = stubTranscriber{err: errors.New("vision api: upstream secret")}
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/grade", strings.NewReader(`{"problem_text":"2x = 6","answer_image_path":"a.png"}`)))
+	if rr.Code != http.StatusBadGateway || strings.Contains(rr.Body.String(), "secret") {
+		t.Errorf("transcribe failure: status = %d, body = %q; want 502 without upstream details", rr.Code, rr.Body)
+	}
+}
@@ -2,45 +2,67 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/explanation"
 	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/review"
 )
 
 // HistoryStore 历史存储接口
 type HistoryStore interface {
-	List() []history.Item
+	Search(q history.Query) (history.Page, error)
+	Get(id string) (*history.Item, bool)
 	Add(it history.Item) string
-	UpdateResult(id string, result *history.Result, taskID string) bool
+	MarkPending(id string) bool
+	AddResult(id string, result *explanation.Result, taskID, model string) string
+	SetPreferred(id, versionID string) bool
+	SetFailed(id string, errMsg string) bool
+	SetTags(id string, tags []string) bool
+	SetPinned(id string, pinned bool) bool
+	SetMistake(id string, m *history.Mistake) bool
+	SetReview(id string, c *review.Card) bool
+	Prune(now time.Time) history.PruneResult
+	RemoveUnreferenced(path string, remove func() error) (bool, error)
 	Delete(id string) bool
-	FindLatestUploadByPath(path string) *history.Item
 }
 
-// HistoryListResponse 列表响应
+// HistoryListResponse 列表响应，next_cursor 非空时可作为 cursor 参数取下一页
 type HistoryListResponse struct {
-	Items []history.Item `json:"items"`
+	Items      []history.Item `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
-// HistoryCreateRequest 创建请求
+// HistoryCreateRequest 创建请求；时间由服务端赋值
 type HistoryCreateRequest struct {
-	Type string `json:"type"` // "upload" | "text"
-	Path string `json:"path,omitempty"`
-	Text string `json:"text,omitempty"`
-	At   int64  `json:"at"`
+	Type   string        `json:"type"` // "upload" | "text"
+	Path   string        `json:"path,omitempty"`
+	Text   string        `json:"text,omitempty"`
+	Region *media.Region `json:"region,omitempty"` // 上传图片中选定的区域，重新解析时沿用
+	Tags   []string      `json:"tags,omitempty"`
 }
 
 // HistoryCreateResponse 创建响应
 type HistoryCreateResponse struct {
 	ID string `json:"id"`
+	At int64  `json:"at"` // 服务端赋值的条目时间（毫秒）
 }
 
-// HistoryUpdateResultRequest 更新结果请求
-type HistoryUpdateResultRequest struct {
-	Result *history.Result `json:"result"`
-	TaskID string         `json:"task_id"`
+// HistoryTagsRequest 设置标签请求（整体替换）
+type HistoryTagsRequest struct {
+	Tags []string `json:"tags"`
 }
 
+// handleHistoryList 检索历史。参数均可选：q 全文检索（题目与步骤内容），type（upload/text），
+// from/to 日期（YYYY-MM-DD，含 to 当天），has_result（true/false），tags（逗号分隔，须全部带有），
+// sort（newest/oldest，默认 newest），cursor（上一页的 next_cursor），limit（默认 50，最大 200）
 func (s *Server) handleHistoryList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -50,10 +72,67 @@ func (s *Server) handleHistoryList(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "history not configured", http.StatusServiceUnavailable)
 		return
 	}
-	items := s.HistoryStore.List()
+	q, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.UserID = caller(r.Context()).scope()
+	page, err := s.HistoryStore.Search(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
-	json.NewEncoder(w).Encode(HistoryListResponse{Items: items})
+	json.NewEncoder(w).Encode(HistoryListResponse{Items: page.Items, NextCursor: page.NextCursor})
+}
+
+// parseHistoryQuery 解析历史检索参数
+func parseHistoryQuery(v url.Values) (history.Query, error) {
+	q := history.Query{Text: v.Get("q"), Type: v.Get("type"), Cursor: v.Get("cursor")}
+	if q.Type != "" && q.Type != "upload" && q.Type != "text" {
+		return q, errors.New("type must be upload or text")
+	}
+	if d := v.Get("from"); d != "" {
+		t, err := time.ParseInLocation("2006-01-02", d, time.Local)
+		if err != nil {
+			return q, errors.New("invalid from, use YYYY-MM-DD")
+		}
+		q.From = t.UnixMilli()
+	}
+	if d := v.Get("to"); d != "" {
+		t, err := time.ParseInLocation("2006-01-02", d, time.Local)
+		if err != nil {
+			return q, errors.New("invalid to, use YYYY-MM-DD")
+		}
+		q.To = t.AddDate(0, 0, 1).UnixMilli()
+	}
+	if b := v.Get("has_result"); b != "" {
+		has, err := strconv.ParseBool(b)
+		if err != nil {
+			return q, errors.New("has_result must be true or false")
+		}
+		q.HasResult = &has
+	}
+	if tags := v.Get("tags"); tags != "" {
+		q.Tags = strings.Split(tags, ",")
+	}
+	switch v.Get("sort") {
+	case "", "newest":
+	case "oldest":
+		q.Oldest = true
+	default:
+		return q, errors.New("sort must be newest or oldest")
+	}
+	if l := v.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		q.Limit = n
+	}
+	return q, nil
 }
 
 func (s *Server) handleHistoryCreate(w http.ResponseWriter, r *http.Request) {
@@ -74,37 +153,41 @@ func (s *Server) handleHistoryCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "type must be upload or text", http.StatusBadRequest)
 		return
 	}
-	it := history.Item{Type: req.Type, Path: req.Path, Text: req.Text, At: req.At}
+	if req.Region != nil {
+		if err := req.Region.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Type == "upload" {
+		absPath, ok := s.uploadPath(req.Path)
+		if !ok || !canUseUpload(caller(r.Context()), absPath) {
+			http.Error(w, errUploadNotFound.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	it := history.Item{UserID: caller(r.Context()).UserID, Type: req.Type, Path: req.Path, Text: req.Text, At: time.Now().UnixMilli(), Region: req.Region, Tags: req.Tags}
 	id := s.HistoryStore.Add(it)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(HistoryCreateResponse{ID: id})
+	json.NewEncoder(w).Encode(HistoryCreateResponse{ID: id, At: it.At})
 }
 
-func (s *Server) handleHistoryUpdateResult(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+func (s *Server) handleHistoryTags(w http.ResponseWriter, r *http.Request) {
 	if s.HistoryStore == nil {
 		http.Error(w, "history not configured", http.StatusServiceUnavailable)
 		return
 	}
 	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "id required", http.StatusBadRequest)
-		return
-	}
-	var req HistoryUpdateResultRequest
+	var req HistoryTagsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	if req.Result == nil {
-		http.Error(w, "result required", http.StatusBadRequest)
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	ok := s.HistoryStore.UpdateResult(id, req.Result, req.TaskID)
-	if !ok {
+	if !s.HistoryStore.SetTags(id, req.Tags) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -125,33 +208,13 @@ func (s *Server) handleHistoryDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "id required", http.StatusBadRequest)
 		return
 	}
-	if !s.HistoryStore.Delete(id) {
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
-}
-
-// handleHistoryFindLatestUpload 供前端“当前上传”解析时拿到要更新的 history id（可选，前端也可用创建时返回的 id）
-func (s *Server) handleHistoryFindLatestUpload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	if s.HistoryStore == nil {
-		http.Error(w, "history not configured", http.StatusServiceUnavailable)
-		return
-	}
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		http.Error(w, "path required", http.StatusBadRequest)
-		return
-	}
-	it := s.HistoryStore.FindLatestUploadByPath(path)
-	if it == nil {
+	if !s.HistoryStore.Delete(id) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"id": it.ID})
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/history"
+)
+
+const maxMistakeTextLen = 5000 // 错题笔记、错误答案最多字符数
+
+// MistakeRequest 加入错题本或更新错题信息；tags 省略时不修改条目标签，传入时整体替换
+type MistakeRequest struct {
+	Note        string    `json:"note"`
+	WrongAnswer string    `json:"wrong_answer"`
+	Category    string    `json:"category"` // concept | calculation | careless | method | other，可为空
+	Tags        *[]string `json:"tags,omitempty"`
+}
+
+// handleMistakeList 错题列表：参数同 GET /history（q 同时检索错题笔记与错误答案），另可按 category 筛选
+func (s *Server) handleMistakeList(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Category = r.URL.Query().Get("category")
+	if !history.ValidCategory(q.Category) {
+		http.Error(w, "category must be one of "+strings.Join(history.MistakeCategories, ", "), http.StatusBadRequest)
+		return
+	}
+	yes := true
+	q.Mistake = &yes
+	q.UserID = caller(r.Context()).scope()
+	page, err := s.HistoryStore.Search(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(HistoryListResponse{Items: page.Items, NextCursor: page.NextCursor})
+}
+
+// handleMistakeSet PUT 将历史加入错题本或更新笔记、错误答案、分类与标签；错题默认不会被保留策略清理
+func (s *Server) handleMistakeSet(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req MistakeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if !history.ValidCategory(req.Category) {
+		http.Error(w, "category must be one of "+strings.Join(history.MistakeCategories, ", "), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Note) > maxMistakeTextLen || utf8.RuneCountInString(req.WrongAnswer) > maxMistakeTextLen {
+		http.Error(w, fmt.Sprintf("note and wrong_answer must be at most %d characters", maxMistakeTextLen), http.StatusBadRequest)
+		return
+	}
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	m := &history.Mistake{Note: strings.TrimSpace(req.Note), WrongAnswer: strings.TrimSpace(req.WrongAnswer), Category: req.Category}
+	if !s.HistoryStore.SetMistake(id, m) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if req.Tags != nil {
+		s.HistoryStore.SetTags(id, *req.Tags)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMistakeDelete 移出错题本，历史条目本身保留
+func (s *Server) handleMistakeDelete(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !s.HistoryStore.SetMistake(id, nil) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
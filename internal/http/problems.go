@@ -0,0 +1,181 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/ocr"
+)
+
+// ProblemSplitter 多题识别：一张图片 → 多道题
+type ProblemSplitter interface {
+	RecognizeProblems(ctx context.Context, imagePath string) ([]ocr.Problem, error)
+}
+
+const (
+	// 批量解析时同时进行的模型调用数
+	batchExplainConcurrency = 3
+	// 单次批量解析最多的题目数，每道题单独计入 explain 限流
+	maxBatchProblems = 10
+)
+
+// ProblemsResponse 图片中识别出的题目列表
+type ProblemsResponse struct {
+	Problems []ocr.Problem `json:"problems"`
+}
+
+// ProblemsExplainRequest 批量解析：problems 为要解析的题目（可为识别结果的子集，文字可由用户修改）；为空时识别并解析全部
+type ProblemsExplainRequest struct {
+	Problems []ocr.Problem `json:"problems"`
+}
+
+// ProblemExplainItem 单题解析结果：成功时返回任务 ID 与历史 ID，失败时返回 error
+type ProblemExplainItem struct {
+	Number    string `json:"number"`
+	Text      string `json:"text"`
+	TaskID    string `json:"task_id,omitempty"`
+	HistoryID string `json:"history_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProblemsExplainResponse 批量解析结果，顺序与请求题目一致
+type ProblemsExplainResponse struct {
+	Items []ProblemExplainItem `json:"items"`
+}
+
+func (s *Server) handleUploadProblems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Splitter == nil {
+		http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
+		return
+	}
+	absPath, err := s.modelImagePath(r.Context(), chi.URLParam(r, "filename"), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, _ := s.withUsage(r.Context(), "problems")
+	problems, err := s.Splitter.RecognizeProblems(ctx, absPath)
+	if err != nil {
+		// 上游识别失败，详情只记日志
+		log.Printf("[problems] recognize %s: %v", absPath, err)
+		http.Error(w, "failed to recognize problems", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProblemsResponse{Problems: problems})
+}
+
+func (s *Server) handleUploadProblemsExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.ExplainGen == nil || s.ExplainStore == nil {
+		http.Error(w, "explanation not configured", http.StatusServiceUnavailable)
+		return
+	}
+	filename := chi.URLParam(r, "filename")
+	absPath, err := s.modelImagePath(r.Context(), filename, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req ProblemsExplainRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	problems := req.Problems
+	if len(problems) > maxBatchProblems {
+		http.Error(w, fmt.Sprintf("too many problems, at most %d per request", maxBatchProblems), http.StatusBadRequest)
+		return
+	}
+	if len(problems) == 0 {
+		if s.Splitter == nil {
+			http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
+			return
+		}
+		if !s.allow(w, r, "ocr") {
+			return
+		}
+		ctx, _ := s.withUsage(r.Context(), "problems")
+		problems, err = s.Splitter.RecognizeProblems(ctx, absPath)
+		if err != nil {
+			log.Printf("[problems] recognize %s: %v", absPath, err)
+			http.Error(w, "failed to recognize problems", http.StatusBadGateway)
+			return
+		}
+		if len(problems) > maxBatchProblems {
+			http.Error(w, fmt.Sprintf("recognized %d problems, at most %d per request: select a subset", len(problems), maxBatchProblems), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, p := range problems {
+		if strings.TrimSpace(p.Text) == "" {
+			http.Error(w, "problem text required", http.StatusBadRequest)
+			return
+		}
+	}
+	// 每道题各计一次 explain，与 /solve 按阶段计数一致
+	for range problems {
+		if !s.allow(w, r, "explain") {
+			return
+		}
+	}
+
+	items := make([]ProblemExplainItem, len(problems))
+	sem := make(chan struct{}, batchExplainConcurrency)
+	var wg sync.WaitGroup
+	for i, p := range problems {
+		wg.Add(1)
+		go func(i int, p ocr.Problem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			items[i] = s.explainProblem(r.Context(), filename, p)
+		}(i, p)
+	}
+	wg.Wait()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProblemsExplainResponse{Items: items})
+}
+
+// explainProblem 解析图片中的一道题，结果作为独立任务保存；先记一条带题号的历史，解析中、完成或失败都记在该条上
+func (s *Server) explainProblem(ctx context.Context, filename string, p ocr.Problem) ProblemExplainItem {
+	item := ProblemExplainItem{Number: p.Number, Text: p.Text}
+	if s.HistoryStore != nil {
+		item.HistoryID = s.HistoryStore.Add(history.Item{
+			UserID:    caller(ctx).UserID,
+			Type:      "upload",
+			Path:      filename,
+			Text:      p.Text,
+			ProblemNo: p.Number,
+		})
+	}
+	ctx, rec := s.withUsage(ctx, "problems-explain")
+	out, err := s.runExplain(ctx, rec, explainInput{
+		problemText: p.Text,
+		imagePath:   filename,
+		stepImages:  true,
+		historyID:   item.HistoryID,
+	})
+	if err != nil {
+		item.Error = explainErrorMessage(err)
+		return item
+	}
+	item.TaskID = out.taskID
+	return item
+}
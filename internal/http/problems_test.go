@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/ocr"
+)
+
+// stubSplitter 返回固定题目，err 非空时返回 err
+type stubSplitter struct {
+	problems []ocr.Problem
+	err      error
+}
+
+func (sp stubSplitter) RecognizeProblems(context.Context, string) ([]ocr.Problem, error) {
+	return sp.problems, sp.err
+}
+
+func TestUploadProblemsUpstreamError(t *testing.T) {
+	s, _ := newTestServer(t, &stubExplainer{})
+	s.Splitter = stubSplitter{err: errors.New("vision api: 401 invalid key sk-secret")}
+	if err := os.WriteFile(filepath.Join(s.UploadDir, "p.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/api/uploads/p.png/problems", "/api/uploads/p.png/explain"} {
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, nil))
+		if rr.Code != http.StatusBadGateway || strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("%s: status = %d, body = %q; want 502 without upstream details", path, rr.Code, rr.Body)
+		}
+	}
+}
+
+// countingLimiter 记录各类别的计数，某类别超过 limit 后拒绝
+type countingLimiter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	limit  map[string]int
+}
+
+func (l *countingLimiter) Allow(class, _ string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[class]++
+	if max, ok := l.limit[class]; ok && l.counts[class] > max {
+		return false, time.Second
+	}
+	return true, 0
+}
+
+func TestUploadProblemsExplainLimits(t *testing.T) {
+	problem := func(n int) ocr.Problem {
+		return ocr.Problem{Number: strconv.Itoa(n), Text: "x + " + strconv.Itoa(n) + " = 10"}
+	}
+	batch := func(n int) []ocr.Problem {
+		ps := make([]ocr.Problem, n)
+		for i := range ps {
+			ps[i] = problem(i + 1)
+		}
+		return ps
+	}
+	body := func(ps []ocr.Problem) string {
+		data, _ := json.Marshal(ProblemsExplainRequest{Problems: ps})
+		return string(data)
+	}
+	tests := []struct {
+		name       string
+		body       string
+		recognized []ocr.Problem
+		limit      map[string]int
+		want       int
+		wantCounts map[string]int
+	}{
+		{"each problem charged", body(batch(3)), nil, nil, http.StatusOK, map[string]int{"explain": 3}},
+		{"recognized charged as ocr", "", batch(2), nil, http.StatusOK, map[string]int{"ocr": 1, "explain": 2}},
+		{"too many problems", body(batch(maxBatchProblems + 1)), nil, nil, http.StatusBadRequest, map[string]int{}},
+		{"too many recognized", "", batch(maxBatchProblems + 1), nil, http.StatusBadRequest, map[string]int{"ocr": 1}},
+		{"explain quota exceeded", body(batch(4)), nil, map[string]int{"explain": 3}, http.StatusTooManyRequests, map[string]int{"explain": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, hist := newTestServer(t, &stubExplainer{})
+			s.Splitter = stubSplitter{problems: tt.recognized}
+			limiter := &countingLimiter{counts: map[string]int{}, limit: tt.limit}
+			s.Limiter = limiter
+			if err := os.WriteFile(filepath.Join(s.UploadDir, "p.png"), []byte("png"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/uploads/p.png/explain", strings.NewReader(tt.body)))
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+			if !reflect.DeepEqual(limiter.counts, tt.wantCounts) {
+				t.Errorf("rate limit counts = %v, want %v", limiter.counts, tt.wantCounts)
+			}
+			if tt.want != http.StatusOK {
+				if page, _ := hist.Search(history.Query{}); len(page.Items) != 0 {
+					t.Errorf("history items = %d, want none for rejected request", len(page.Items))
+				}
+			}
+		})
+	}
+}
+
+// failTextExplainer 题目文字为 fail 时解析失败
+type failTextExplainer struct {
+	stubExplainer
+	fail string
+}
+
+func (g *failTextExplainer) Generate(ctx context.Context, problemText string) (*explanation.Result, error) {
+	if problemText == g.fail {
+		return nil, errors.New("upstream error")
+	}
+	return g.stubExplainer.Generate(ctx, problemText)
+}
+
+func TestUploadProblemsExplainHistory(t *testing.T) {
+	s, hist := newTestServer(t, &failTextExplainer{fail: "x + 2 = 10"})
+	if err := os.WriteFile(filepath.Join(s.UploadDir, "p.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"problems":[{"number":"1","text":"x + 1 = 10"},{"number":"2","text":"x + 2 = 10"}]}`
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/uploads/p.png/explain", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	var resp ProblemsExplainResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 2 {
+		t.Fatalf("items = %+v", resp.Items)
+	}
+
+	ok, failed := resp.Items[0], resp.Items[1]
+	if ok.TaskID == "" || ok.Error != "" || ok.HistoryID == "" {
+		t.Fatalf("first item = %+v, want task and history", ok)
+	}
+	it, found := hist.Get(ok.HistoryID)
+	if !found || it.Status != history.StatusDone || it.ProblemNo != "1" || it.Path != "p.png" || it.TaskID != ok.TaskID {
+		t.Errorf("first history item = %+v", it)
+	}
+	if res, _ := s.ExplainStore.Get(ok.TaskID); res == nil || res.ImagePath != "p.png" {
+		t.Errorf("result = %+v, want image path p.png", res)
+	}
+
+	// 失败的题也留下历史条目，标记为失败
+	if failed.TaskID != "" || failed.Error == "" || failed.HistoryID == "" {
+		t.Fatalf("second item = %+v, want error with history", failed)
+	}
+	if it, found := hist.Get(failed.HistoryID); !found || it.Status != history.StatusFailed || it.Error == "" || it.ProblemNo != "2" {
+		t.Errorf("failed history item = %+v", it)
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimiter 按接口类别（upload、ocr、explain、login）与客户端限流
+type RateLimiter interface {
+	Allow(class, client string) (bool, time.Duration)
+}
+
+// limit 返回某类接口的限流中间件：超出额度时返回 429 并在 Retry-After 中给出需等待的秒数
+func (s *Server) limit(class string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.allow(w, r, class) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow 按 class 计入一次请求；超出额度时写入 429 响应并返回 false。用于一次请求包含多个阶段、按实际执行的阶段计数的接口
+func (s *Server) allow(w http.ResponseWriter, r *http.Request, class string) bool {
+	if s.Limiter == nil {
+		return true
+	}
+	ok, wait := s.Limiter.Allow(class, s.clientID(r))
+	if ok {
+		return true
+	}
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, fmt.Sprintf("rate limit exceeded, retry after %ds", secs), http.StatusTooManyRequests)
+	return false
+}
+
+// clientID 识别客户端：已通过鉴权（登录或 API Key）时按用户计数，否则按 IP；未校验的凭据头不参与计数，
+// 避免每次换一个伪造的 Key 绕过限流。仅在 TrustProxy 时采信 X-Forwarded-For，避免客户端伪造来源绕过限流。
+func (s *Server) clientID(r *http.Request) string {
+	if p := caller(r.Context()); p.UserID != "" {
+		return "user:" + p.UserID
+	}
+	if s.TrustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			ip, _, _ := strings.Cut(fwd, ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
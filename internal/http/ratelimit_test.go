@@ -0,0 +1,44 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/ratelimit"
+)
+
+func TestClientIDIgnoresUnverifiedKey(t *testing.T) {
+	s := &Server{}
+	a := httptest.NewRequest(http.MethodPost, "/api/explain", nil)
+	a.Header.Set("X-API-Key", "forged-1")
+	b := httptest.NewRequest(http.MethodPost, "/api/explain", nil)
+	b.Header.Set("Authorization", "Bearer forged-2")
+	if s.clientID(a) != s.clientID(b) {
+		t.Fatalf("unverified keys counted separately: %q vs %q", s.clientID(a), s.clientID(b))
+	}
+
+	u := b.WithContext(context.WithValue(b.Context(), principalKey{}, principal{UserID: "u1"}))
+	if got := s.clientID(u); got != "user:u1" {
+		t.Fatalf("clientID = %q, want user:u1", got)
+	}
+}
+
+func TestLoginRateLimited(t *testing.T) {
+	s, _ := newTestServer(t, &stubExplainer{})
+	s.Limiter = ratelimit.NewSet(config.RateLimitConfig{Login: config.RateLimit{PerMinute: 1, Burst: 1}})
+	login := func() int {
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"a","password":"b"}`)))
+		return rr.Code
+	}
+	if code := login(); code == http.StatusTooManyRequests {
+		t.Fatal("first login attempt rate limited")
+	}
+	if code := login(); code != http.StatusTooManyRequests {
+		t.Fatalf("second login attempt status = %d, want 429", code)
+	}
+}
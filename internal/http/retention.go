@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/media"
+)
+
+// PruneHistory 按保留策略清理历史，并删除被清理条目引用、且不再被其余历史引用的上传图片（含元信息与派生图）与解析结果
+func (s *Server) PruneHistory() int {
+	if s.HistoryStore == nil {
+		return 0
+	}
+	res := s.HistoryStore.Prune(time.Now())
+	if len(res.Removed) == 0 {
+		return 0
+	}
+	for _, name := range res.Paths {
+		absPath, ok := s.uploadPath(name)
+		if !ok {
+			continue
+		}
+		// 清理后到删除前可能又有条目引用同一图片（如重新上传），删除前在历史锁内再确认一次
+		if _, err := s.HistoryStore.RemoveUnreferenced(name, func() error { return media.RemoveUpload(absPath) }); err != nil {
+			log.Printf("[history] remove upload %s: %v", name, err)
+		}
+	}
+	if s.ExplainStore != nil {
+		for _, id := range res.TaskIDs {
+			s.ExplainStore.Delete(id)
+		}
+	}
+	log.Printf("[history] pruned %d items, %d uploads, %d results", len(res.Removed), len(res.Paths), len(res.TaskIDs))
+	return len(res.Removed)
+}
+
+// RunRetention 立即清理一次，之后每隔 interval 清理，直到 ctx 结束
+func (s *Server) RunRetention(ctx context.Context, interval time.Duration) {
+	s.PruneHistory()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.PruneHistory()
+		}
+	}
+}
+
+// handleHistoryPin PUT 置顶、DELETE 取消置顶；置顶条目不会被保留策略清理
+func (s *Server) handleHistoryPin(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !s.HistoryStore.SetPinned(id, r.Method == http.MethodPut) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/review"
+)
+
+const defaultReviewLimit = 20
+
+// ReviewDueResponse 今日待复习：按到期时间先后排列，Total 为到期总数（Items 最多 limit 条）
+type ReviewDueResponse struct {
+	Items []history.Item `json:"items"`
+	Total int            `json:"total"`
+}
+
+// ReviewRequest 记录一次复习的自评
+type ReviewRequest struct {
+	Grade string `json:"grade"` // again | hard | good | easy
+}
+
+// handleReviewDue 今日（服务器时区，截至当天结束）待复习的题：复习到期的历史与尚未复习过的错题。limit 默认 20，最大 200
+func (s *Server) handleReviewDue(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	limit := defaultReviewLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, history.MaxLimit)
+	}
+	y, m, d := time.Now().Date()
+	endOfDay := time.Date(y, m, d+1, 0, 0, 0, 0, time.Local).UnixMilli() - 1
+	items, err := s.allHistory(history.Query{UserID: caller(r.Context()).scope(), DueBy: endOfDay})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool { return reviewDue(&items[i]) < reviewDue(&items[j]) })
+	resp := ReviewDueResponse{Items: make([]history.Item, 0), Total: len(items)}
+	resp.Items = append(resp.Items, items[:min(len(items), limit)]...)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// reviewDue 排序用的到期时间：尚未复习过的错题以加入错题本的时间计
+func reviewDue(it *history.Item) int64 {
+	if it.Review != nil {
+		return it.Review.Due
+	}
+	if it.Mistake != nil {
+		return it.Mistake.At
+	}
+	return it.At
+}
+
+// handleReviewGrade 记录一次复习自评并按 SM-2 重新排期，返回更新后的复习状态；未复习过的条目由此加入复习计划
+func (s *Server) handleReviewGrade(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	grade, err := review.ParseGrade(req.Grade)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	it, ok := s.HistoryStore.Get(id)
+	if !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	card := review.New(now)
+	if it.Review != nil {
+		card = *it.Review
+	}
+	card = card.Next(grade, now)
+	if !s.HistoryStore.SetReview(id, &card) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
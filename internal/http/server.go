@@ -22,7 +22,20 @@ type Server struct {
 	ExplainGen   ExplainGenerator    // 可选
 	ExplainStore ExplainStore        // 可选
 	ImageGen     StepImageGenerator  // 可选，为每步生成讲解图
-	HistoryStore HistoryStore       // 可选，解析历史
+	HistoryStore HistoryStore        // 可选，解析历史
+	Transcriber  SolutionTranscriber // 可选，学生作答识别
+	Grader       SolutionGrader      // 可选，作答批改
+	Tutor        StepTutor           // 可选，苏格拉底式辅导
+	TutorStore   TutorStore          // 可选，辅导会话存储
+	Splitter     ProblemSplitter     // 可选，多题图片拆分
+	Media        MediaProcessor      // 可选，上传校验与送入模型前的图片预处理
+	Cache        ResultCache         // 可选，按图片内容或规范化题目文本缓存识图与解析结果
+	Usage        UsageLedger         // 可选，模型调用用量与费用台账
+	Limiter      RateLimiter         // 可选，按客户端与接口类别限流
+	TrustProxy   bool                // 采信 X-Forwarded-For / -Proto / -Host 识别客户端与外部地址（部署在反向代理后时开启）
+	Auth         Authenticator       // 可选，开启后接口需登录或 API Key，数据按用户隔离
+
+	flights flightGroup // 合并相同题目的并发解析请求
 }
 
 // NewServer 创建 HTTP 服务，uploadDir 为图片落盘目录，maxSizeMB 为单文件最大 MB；ocr/gen/store/imageGen/historyStore 可为 nil
@@ -42,16 +55,48 @@ func NewServer(uploadDir string, maxSizeMB int, ocr OCRRecognizer, explainGen Ex
 	}
 	s.Router.Use(middleware.Logger, middleware.Recoverer)
 	s.Router.Route("/api", func(r chi.Router) {
-		r.Post("/upload", s.handleUpload)
-		r.Get("/uploads/{filename}", s.handleServeUpload)
-		r.Post("/submit", s.handleSubmit)
-		r.Post("/explain", s.handleExplain)
-		r.Get("/result/{id}", s.handleResult)
-		r.Get("/history/find-upload", s.handleHistoryFindLatestUpload)
-		r.Get("/history", s.handleHistoryList)
-		r.Post("/history", s.handleHistoryCreate)
-		r.Patch("/history/{id}", s.handleHistoryUpdateResult)
-		r.Delete("/history/{id}", s.handleHistoryDelete)
+		r.With(s.limit("login")).Post("/auth/login", s.handleLogin)
+		r.Post("/auth/logout", s.handleLogout)
+		r.Get("/auth/me", s.handleMe)
+		r.Group(func(r chi.Router) {
+			r.Use(s.authenticate)
+			r.Post("/auth/users", s.handleCreateUser)
+			r.Post("/auth/keys", s.handleAPIKeyCreate)
+			r.Get("/auth/keys", s.handleAPIKeyList)
+			r.Delete("/auth/keys/{id}", s.handleAPIKeyDelete)
+			r.With(s.limit("upload")).Post("/upload", s.handleUpload)
+			r.Get("/uploads/{filename}", s.handleServeUpload)
+			r.With(s.limit("ocr")).Post("/uploads/{filename}/problems", s.handleUploadProblems)
+			r.Post("/uploads/{filename}/explain", s.handleUploadProblemsExplain) // 按识图与每道题分别限流
+			r.With(s.limit("ocr")).Post("/submit", s.handleSubmit)
+			r.With(s.limit("explain")).Post("/explain", s.handleExplain)
+			r.Post("/solve", s.handleSolve) // 按实际执行的上传、识图、解析阶段分别限流
+			r.Get("/result/{id}", s.handleResult)
+			r.Get("/result/{id}/export", s.handleResultExport)
+			r.Get("/cache/stats", s.handleCacheStats)
+			r.Get("/usage", s.handleUsage)
+			r.With(s.limit("explain")).Post("/grade", s.handleGrade)
+			r.With(s.limit("explain")).Post("/tutor", s.handleTutorCreate)
+			r.Get("/tutor/{id}", s.handleTutorGet)
+			r.With(s.limit("explain")).Post("/tutor/{id}/steps", s.handleTutorStep)
+			r.Get("/history", s.handleHistoryList)
+			r.Get("/history/export", s.handleHistoryExport)
+			r.Get("/history/worksheet", s.handleHistoryWorksheet)
+			r.With(s.limit("upload")).Post("/history/import", s.handleHistoryImport)
+			r.Post("/history", s.handleHistoryCreate)
+			r.Put("/history/{id}/tags", s.handleHistoryTags)
+			r.Put("/history/{id}/pin", s.handleHistoryPin)
+			r.Delete("/history/{id}/pin", s.handleHistoryPin)
+			r.Delete("/history/{id}", s.handleHistoryDelete)
+			r.With(s.limit("explain")).Post("/history/{id}/regenerate", s.handleHistoryRegenerate)
+			r.Put("/history/{id}/preferred", s.handleHistoryPreferred)
+			r.Get("/history/{id}/diff", s.handleHistoryDiff)
+			r.Get("/mistakes", s.handleMistakeList)
+			r.Put("/mistakes/{id}", s.handleMistakeSet)
+			r.Delete("/mistakes/{id}", s.handleMistakeDelete)
+			r.Get("/review/due", s.handleReviewDue)
+			r.Post("/review/{id}", s.handleReviewGrade)
+		})
 	})
 	return s
 }
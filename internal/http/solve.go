@@ -0,0 +1,225 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/usage"
+)
+
+// 一键解题的图片处理方式
+const (
+	solveModeAuto   = "auto"   // 配置了识图时先识图再按文字解析，否则看图解析
+	solveModeOCR    = "ocr"    // 识图得到题目文字后按文字解析
+	solveModeVision = "vision" // 直接让解析模型看图
+	solveModeText   = "text"   // 文字输入
+)
+
+// SolveResponse 一键解题结果；explain=false 时只有上传与识图结果
+type SolveResponse struct {
+	Mode        string          `json:"mode"`                   // 实际采用的方式：text / ocr / vision
+	ImagePath   string          `json:"image_path,omitempty"`   // 上传后的图片（相对 upload 目录）
+	ProblemText string          `json:"problem_text,omitempty"` // 输入或识图得到的题目文字，看图解析时为模型转写
+	TaskID      string          `json:"task_id,omitempty"`      // 解析任务，可用于 GET /api/result/:id 与导出
+	HistoryID   string          `json:"history_id,omitempty"`   // 记录本题的历史条目，history=false 或未配置历史时为空
+	Cached      bool            `json:"cached,omitempty"`       // 识图或解析结果来自缓存
+	Result      *ResultResponse `json:"result,omitempty"`
+	Usage       *usage.Summary  `json:"usage,omitempty"` // 本次调用全部模型用量与费用（识图 + 解析）
+}
+
+// solveOptions 一键解题各阶段的开关，来自表单字段
+type solveOptions struct {
+	mode       string
+	region     *media.Region
+	explain    bool // false 时只上传与识图
+	stepImages bool
+	history    bool
+	regenerate bool
+	tags       []string
+}
+
+// handleSolve 一键解题（multipart/form-data）：file 图片与 text 文字二选一，依次完成上传、识图、解析、讲解图与历史记录，
+// 供机器人等集成一次调用完成前端的多步流程。可选字段：mode（auto/ocr/vision，默认 auto）、region（JSON，只处理图片中的区域）、
+// explain、step_images、history（默认 true）、regenerate（默认 false）、tags（逗号分隔，记入历史）。
+// 限流按实际执行的阶段分别计入 upload、ocr、explain 额度
+func (s *Server) handleSolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	maxBytes := int64(s.MaxSizeMB) * 1024 * 1024
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		http.Error(w, "file too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	opts, err := parseSolveOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text := strings.TrimSpace(r.FormValue("text"))
+	hasFile := len(r.MultipartForm.File["file"]) > 0
+	if text != "" && hasFile {
+		http.Error(w, "provide either file or text, not both", http.StatusBadRequest)
+		return
+	}
+	if text == "" && !hasFile {
+		http.Error(w, "file or text required", http.StatusBadRequest)
+		return
+	}
+	if opts.explain && (s.ExplainGen == nil || s.ExplainStore == nil) {
+		http.Error(w, "explanation not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if opts.history && s.HistoryStore == nil {
+		opts.history = false
+	}
+
+	p := caller(r.Context())
+	ctx, rec := s.withUsage(r.Context(), "solve")
+	resp := SolveResponse{Mode: solveModeText, ProblemText: text}
+	var absPath string
+	if text != "" {
+		if err := s.checkProblemText(text); err != nil {
+			http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+			return
+		}
+	} else {
+		data, filename, err := s.readFormFile(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.allow(w, r, "upload") {
+			return
+		}
+		up, err := s.storeUpload(p.UserID, filename, data)
+		if err != nil {
+			var ue *uploadError
+			if errors.As(err, &ue) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("[solve] upload: %v", err)
+			http.Error(w, "failed to save file", http.StatusInternalServerError)
+			return
+		}
+		resp.ImagePath = up.Path
+		if absPath, err = s.modelImagePath(ctx, up.Path, opts.region); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Mode = opts.mode
+		if resp.Mode == solveModeAuto {
+			resp.Mode = solveModeVision
+			if s.OCR != nil {
+				resp.Mode = solveModeOCR
+			}
+		}
+		if resp.Mode == solveModeVision && !opts.explain {
+			// 不解析时看图没有可做的，退回识图
+			resp.Mode = solveModeOCR
+		}
+		if resp.Mode == solveModeOCR {
+			if s.OCR == nil {
+				http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
+				return
+			}
+			if !s.allow(w, r, "ocr") {
+				return
+			}
+			var cached bool
+			resp.ProblemText, cached, err = s.recognizeCached(ctx, absPath)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			resp.Cached = cached
+			absPath = "" // 按识图文字解析
+			if opts.explain {
+				if err := s.checkProblemText(resp.ProblemText); err != nil {
+					http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+					return
+				}
+			}
+		}
+	}
+
+	if opts.explain && !s.allow(w, r, "explain") {
+		return
+	}
+	if opts.history {
+		it := history.Item{UserID: p.UserID, Type: "text", Text: resp.ProblemText, Tags: opts.tags}
+		if resp.ImagePath != "" {
+			// 识图文字一并记录，便于检索；重新解析时按文字解析
+			it = history.Item{UserID: p.UserID, Type: "upload", Path: resp.ImagePath, Text: resp.ProblemText, Region: opts.region, Tags: opts.tags}
+		}
+		resp.HistoryID = s.HistoryStore.Add(it)
+	}
+	if opts.explain {
+		out, err := s.runExplain(ctx, rec, explainInput{
+			problemText: resp.ProblemText,
+			imagePath:   resp.ImagePath,
+			absPath:     absPath,
+			regenerate:  opts.regenerate,
+			stepImages:  opts.stepImages,
+			historyID:   resp.HistoryID,
+		})
+		if err != nil {
+			http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+			return
+		}
+		res := toResultResponse(out.result)
+		resp.TaskID, resp.Result = out.taskID, &res
+		resp.Cached = resp.Cached || out.cached
+		if resp.ProblemText == "" {
+			resp.ProblemText = out.result.Problem
+		}
+	}
+	resp.Usage = rec.Summary()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseSolveOptions 解析一键解题的表单选项
+func parseSolveOptions(r *http.Request) (solveOptions, error) {
+	opts := solveOptions{mode: solveModeAuto, explain: true, stepImages: true, history: true}
+	switch m := r.FormValue("mode"); m {
+	case "":
+	case solveModeAuto, solveModeOCR, solveModeVision:
+		opts.mode = m
+	default:
+		return opts, errors.New("mode must be auto, ocr or vision")
+	}
+	if v := r.FormValue("region"); v != "" {
+		opts.region = &media.Region{}
+		if err := json.Unmarshal([]byte(v), opts.region); err != nil {
+			return opts, errors.New("invalid region json")
+		}
+	}
+	for name, dst := range map[string]*bool{
+		"explain":     &opts.explain,
+		"step_images": &opts.stepImages,
+		"history":     &opts.history,
+		"regenerate":  &opts.regenerate,
+	} {
+		if v := r.FormValue(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, errors.New(name + " must be true or false")
+			}
+			*dst = b
+		}
+	}
+	if v := r.FormValue("tags"); v != "" {
+		opts.tags = strings.Split(v, ",")
+	}
+	return opts, nil
+}
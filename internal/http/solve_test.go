@@ -0,0 +1,59 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/ratelimit"
+)
+
+type stubOCR struct{}
+
+func (stubOCR) Recognize(context.Context, string) (string, error) { return "2x + 4 = 10", nil }
+
+// solveRequest 构造一键解题请求：file 非空时上传图片，否则按 text 解析
+func solveRequest(t *testing.T, fields map[string]string, file []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	if file != nil {
+		fw, _ := mw.CreateFormFile("file", "q.png")
+		fw.Write(file)
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/solve", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestSolveChargesStagesRun(t *testing.T) {
+	s, _ := newTestServer(t, &stubExplainer{})
+	s.OCR = stubOCR{}
+	one := config.RateLimit{PerMinute: 1, Burst: 1}
+	s.Limiter = ratelimit.NewSet(config.RateLimitConfig{Upload: config.RateLimit{PerMinute: 1, Burst: 2}, OCR: one, Explain: one})
+	img := testPNG(t)
+	for i, tc := range []struct {
+		fields map[string]string
+		file   []byte
+		want   int
+	}{
+		{map[string]string{"text": "x + 1 = 2"}, nil, http.StatusOK},              // explain
+		{map[string]string{"text": "x + 2 = 3"}, nil, http.StatusTooManyRequests}, // explain 额度用完
+		{map[string]string{"explain": "false"}, img, http.StatusOK},               // 只上传与识图，不计 explain
+		{map[string]string{"explain": "false"}, img, http.StatusTooManyRequests},  // ocr 额度用完
+	} {
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, solveRequest(t, tc.fields, tc.file))
+		if rr.Code != tc.want {
+			t.Errorf("request %d: status = %d, want %d: %s", i, rr.Code, tc.want, rr.Body)
+		}
+	}
+}
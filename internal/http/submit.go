@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"path/filepath"
+
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/usage"
 )
 
 // OCRRecognizer 识图能力：图片路径 → 题目文本
@@ -12,15 +14,31 @@ type OCRRecognizer interface {
 	Recognize(ctx context.Context, imagePath string) (string, error)
 }
 
+// inputChecker 调用模型前检查题目文本长度
+type inputChecker interface {
+	CheckInput(problemText string) error
+}
+
+// checkProblemText 按解析模型的 max_input_tokens 检查题目文本，未配置解析时不限制
+func (s *Server) checkProblemText(text string) error {
+	if c, ok := s.ExplainGen.(inputChecker); ok {
+		return c.CheckInput(text)
+	}
+	return nil
+}
+
 // SubmitRequest 提交题目：仅文本，或先传图后的图片路径（相对 upload 目录）
 type SubmitRequest struct {
-	Text      string `json:"text"`       // 直接题目文字
-	ImagePath string `json:"image_path"` // 已上传图片路径（相对 upload 目录），与 text 二选一
+	Text      string        `json:"text"`             // 直接题目文字
+	ImagePath string        `json:"image_path"`       // 已上传图片路径（相对 upload 目录），与 text 二选一
+	Region    *media.Region `json:"region,omitempty"` // 可选：只识别图片中的某个区域（裁剪 + 旋转）
 }
 
 // SubmitResponse 统一返回题目文本，供前端展示/编辑或发起解析
 type SubmitResponse struct {
-	ProblemText string `json:"problem_text"`
+	ProblemText string         `json:"problem_text"`
+	Cached      bool           `json:"cached,omitempty"` // 识图结果来自缓存（同一图片此前已识别过）
+	Usage       *usage.Summary `json:"usage,omitempty"`  // 识图的模型用量与费用，命中缓存时为空
 }
 
 func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +61,10 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Text != "" {
+		if err := s.checkProblemText(req.Text); err != nil {
+			http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(SubmitResponse{ProblemText: req.Text})
 		return
@@ -53,12 +75,17 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
 		return
 	}
-	absPath := filepath.Join(s.UploadDir, req.ImagePath)
-	text, err := s.OCR.Recognize(r.Context(), absPath)
+	absPath, err := s.modelImagePath(r.Context(), req.ImagePath, req.Region)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, rec := s.withUsage(r.Context(), "submit")
+	text, cached, err := s.recognizeCached(ctx, absPath)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(SubmitResponse{ProblemText: text})
+	json.NewEncoder(w).Encode(SubmitResponse{ProblemText: text, Cached: cached, Usage: rec.Summary()})
 }
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+	"github.com/gomath/gomath/internal/mathcheck"
+	"github.com/gomath/gomath/internal/tutor"
+)
+
+// StepTutor 苏格拉底式辅导：判断学生的下一步并给出引导
+type StepTutor interface {
+	Tutor(ctx context.Context, problemText string, accepted []string, step, hint string) (*explanation.TutorReply, error)
+}
+
+// TutorStore 辅导会话存储
+type TutorStore interface {
+	Create(sess tutor.Session) string
+	Get(id string) (*tutor.Session, bool)
+	AppendTurn(id string, turns int, turn tutor.Turn, done bool) (*tutor.Session, error)
+}
+
+// TutorCreateRequest 创建辅导会话：problem_text 与 history_id 二选一；不传 history_id 时自动创建一条文字历史
+type TutorCreateRequest struct {
+	ProblemText string `json:"problem_text"`
+	HistoryID   string `json:"history_id"`
+}
+
+// TutorStepRequest 学生提交下一步
+type TutorStepRequest struct {
+	Step string `json:"step"`
+}
+
+// TutorStepResponse 本轮回应及更新后的会话
+type TutorStepResponse struct {
+	Turn    tutor.Turn     `json:"turn"`
+	Session *tutor.Session `json:"session"`
+}
+
+func (s *Server) handleTutorCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Tutor == nil || s.TutorStore == nil || s.HistoryStore == nil {
+		http.Error(w, "tutor not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req TutorCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if (req.ProblemText == "") == (req.HistoryID == "") {
+		http.Error(w, "provide either problem_text or history_id", http.StatusBadRequest)
+		return
+	}
+	problemText := strings.TrimSpace(req.ProblemText)
+	historyID := req.HistoryID
+	if historyID != "" {
+		it, ok := s.HistoryStore.Get(historyID)
+		if !ok || !caller(r.Context()).canAccess(it.UserID) {
+			http.Error(w, "history item not found", http.StatusNotFound)
+			return
+		}
+		problemText = it.Text
+		if problemText == "" && it.Type == "upload" {
+			if s.OCR == nil {
+				http.Error(w, "ocr not configured", http.StatusServiceUnavailable)
+				return
+			}
+			absPath, err := s.modelImagePath(r.Context(), it.Path, it.Region)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ctx, _ := s.withUsage(r.Context(), "tutor")
+			text, _, err := s.recognizeCached(ctx, absPath)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			problemText = text
+		}
+		if problemText == "" {
+			http.Error(w, "history item has no problem text", http.StatusBadRequest)
+			return
+		}
+	} else {
+		historyID = s.HistoryStore.Add(history.Item{UserID: caller(r.Context()).UserID, Type: "text", Text: problemText, At: time.Now().UnixMilli()})
+	}
+	id := s.TutorStore.Create(tutor.Session{UserID: caller(r.Context()).UserID, HistoryID: historyID, ProblemText: problemText})
+	sess, _ := s.TutorStore.Get(id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sess)
+}
+
+func (s *Server) handleTutorGet(w http.ResponseWriter, r *http.Request) {
+	if s.TutorStore == nil {
+		http.Error(w, "tutor not configured", http.StatusServiceUnavailable)
+		return
+	}
+	sess, ok := s.TutorStore.Get(chi.URLParam(r, "id"))
+	if !ok || !caller(r.Context()).canAccess(sess.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(sess)
+}
+
+func (s *Server) handleTutorStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Tutor == nil || s.TutorStore == nil {
+		http.Error(w, "tutor not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req TutorStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	step := strings.TrimSpace(req.Step)
+	if step == "" {
+		http.Error(w, "step required", http.StatusBadRequest)
+		return
+	}
+	sess, ok := s.TutorStore.Get(id)
+	if !ok || !caller(r.Context()).canAccess(sess.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if sess.Done {
+		http.Error(w, "session already finished", http.StatusConflict)
+		return
+	}
+
+	// 本地等价性检查：与上一步（首步时与题目中的公式）比较
+	prev := mathcheck.LastStatement(sess.ProblemText)
+	if len(sess.Steps) > 0 {
+		prev = sess.Steps[len(sess.Steps)-1]
+	}
+	var verdict mathcheck.Verdict
+	if prev != "" {
+		verdict = mathcheck.CheckStep(prev, step)
+	}
+	hint := ""
+	if verdict.Checked {
+		switch {
+		case verdict.Equivalent:
+			hint = "这一步与上一步等价（" + verdict.Reason + "）"
+		case verdict.Subset:
+			hint = "这一步取了上一步的部分解，分情况讨论时成立，注意其余情况（" + verdict.Reason + "）"
+		default:
+			hint = "这一步与上一步不等价（" + verdict.Reason + "）"
+		}
+	}
+
+	ctx, _ := s.withUsage(r.Context(), "tutor")
+	reply, err := s.Tutor.Tutor(ctx, sess.ProblemText, sess.Steps, step, hint)
+	if err != nil {
+		log.Printf("[tutor] error: %v", err)
+		http.Error(w, explainErrorMessage(err), explainErrorStatus(err))
+		return
+	}
+	turn := tutor.Turn{Step: step, Accepted: reply.Valid, Reply: reply.Reply, LocalCheck: hint}
+	// 本地检查能确定不等价时，以本地结论为准；只取部分解（分情况讨论）时交给模型判断
+	if verdict.Checked && !verdict.Equivalent && !verdict.Subset && reply.Valid {
+		turn.Accepted = false
+		turn.Reply = "这一步和上一步好像不是同解变形（" + verdict.Reason + "）。你能检查一下变形过程中哪里出了问题吗？"
+	}
+	updated, err := s.TutorStore.AppendTurn(id, len(sess.Turns), turn, turn.Accepted && reply.Done)
+	if errors.Is(err, tutor.ErrStale) {
+		http.Error(w, "session changed, reload and retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TutorStepResponse{Turn: updated.Turns[len(updated.Turns)-1], Session: updated})
+}
@@ -1,27 +1,30 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/persist"
 )
 
-// 允许的图片类型
-var allowedImageTypes = map[string]bool{
-	"image/jpeg": true,
-	"image/png":  true,
-	"image/webp": true,
-}
-
 // UploadResponse 上传成功响应
 type UploadResponse struct {
-	Path string `json:"path"` // 相对路径，用于后续识图等
+	Path      string `json:"path"`      // 相对路径，用于后续识图等
+	MIME      string `json:"mime"`      // 按文件内容识别出的类型
+	Width     int    `json:"width"`     // 图片宽（像素）
+	Height    int    `json:"height"`    // 图片高（像素）
+	Duplicate bool   `json:"duplicate"` // 相同内容此前已上传过，复用已有文件
 }
 
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
@@ -34,60 +37,106 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "file too large or invalid form", http.StatusBadRequest)
 		return
 	}
-	file, header, err := r.FormFile("file")
+	data, filename, err := s.readFormFile(r)
 	if err != nil {
-		http.Error(w, "missing or invalid file field", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
-
-	ct := header.Header.Get("Content-Type")
-	if ct != "" {
-		ct = strings.TrimSpace(strings.Split(ct, ";")[0])
-	}
-	if !allowedImageTypes[ct] {
-		http.Error(w, "unsupported file type, use JPEG/PNG/WebP", http.StatusBadRequest)
+	resp, err := s.storeUpload(caller(r.Context()).UserID, filename, data)
+	if err != nil {
+		var ue *uploadError
+		if errors.As(err, &ue) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[upload] %v", err)
+		http.Error(w, "failed to save file", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
+var (
+	errMissingFile  = errors.New("missing or invalid file field")
+	errFileTooLarge = errors.New("file too large")
+)
+
+// readFormFile 读取已解析的 multipart 表单中的 file 字段，返回内容与客户端文件名；超过 MaxSizeMB 时报错
+func (s *Server) readFormFile(r *http.Request) ([]byte, string, error) {
+	maxBytes := int64(s.MaxSizeMB) * 1024 * 1024
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", errMissingFile
+	}
+	defer file.Close()
 	if header.Size > maxBytes {
-		http.Error(w, "file too large", http.StatusBadRequest)
-		return
+		return nil, "", errFileTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, "", errors.New("failed to read file")
 	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", errFileTooLarge
+	}
+	return data, header.Filename, nil
+}
 
+// uploadError 图片本身不合格（类型、尺寸、扩展名等），对应 400
+type uploadError struct{ err error }
+
+func (e *uploadError) Error() string { return e.err.Error() }
+func (e *uploadError) Unwrap() error { return e.err }
+
+// storeUpload 校验图片并按内容哈希落盘，owner 记入元信息的上传者列表；图片不合格时返回 *uploadError
+func (s *Server) storeUpload(owner, filename string, data []byte) (*UploadResponse, error) {
+	meta, name, err := s.checkUpload(filename, data)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(s.UploadDir, 0755); err != nil {
-		http.Error(w, "server config error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		switch ct {
-		case "image/jpeg":
-			ext = ".jpg"
-		case "image/png":
-			ext = ".png"
-		case "image/webp":
-			ext = ".webp"
-		default:
-			ext = ".bin"
+	fpath := filepath.Join(s.UploadDir, name)
+	_, statErr := os.Stat(fpath)
+	duplicate := statErr == nil
+	if !duplicate {
+		if err := persist.WriteFile(fpath, data, 0644, false); err != nil {
+			return nil, err
 		}
 	}
-	name := uuid.New().String() + ext
-	fpath := filepath.Join(s.UploadDir, name)
-	dst, err := os.Create(fpath)
+	// 同一内容可能被多个用户上传，元信息中记录所有上传者，各自只能访问自己上传过的图片；
+	// 读改写在 AddOwner 内串行进行，并发上传同一图片时不会丢失上传者
+	added, err := media.AddOwner(fpath, meta, owner)
 	if err != nil {
-		http.Error(w, "failed to save file", http.StatusInternalServerError)
-		return
+		log.Printf("[upload] write meta %s: %v", name, err)
 	}
-	defer dst.Close()
-	if _, err := io.Copy(dst, file); err != nil {
-		os.Remove(fpath)
-		http.Error(w, "failed to save file", http.StatusInternalServerError)
-		return
+	// 其他用户上传过不算重复，避免泄露他人上传过哪些图片
+	duplicate = duplicate && !added
+	return &UploadResponse{Path: name, MIME: meta.MIME, Width: meta.Width, Height: meta.Height, Duplicate: duplicate}, nil
+}
+
+// checkUpload 校验图片并返回落盘时的文件名，不写文件；图片不合格时返回 *uploadError
+func (s *Server) checkUpload(filename string, data []byte) (*media.Meta, string, error) {
+	// 不信任客户端的 Content-Type 与扩展名：按文件头识别类型并完整解码校验
+	meta, err := s.inspectImage(data)
+	if err != nil {
+		return nil, "", &uploadError{err}
+	}
+	if !media.ExtMatches(filename, meta.MIME) {
+		return nil, "", &uploadError{media.ErrExtensionMismatch}
 	}
+	// 按内容哈希命名：同一张图多次上传只存一份，后续识图与解析可命中缓存
+	return meta, media.HashBytes(data) + meta.Ext(), nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(UploadResponse{Path: name})
+// inspectImage 校验上传图片；未配置预处理时按默认限制校验
+func (s *Server) inspectImage(data []byte) (*media.Meta, error) {
+	if s.Media != nil {
+		return s.Media.Inspect(data)
+	}
+	return media.Inspect(data, config.MediaConfig{})
 }
 
 // handleServeUpload 提供已上传图片的访问，用于前端预览；filename 仅允许单级路径（无 / 与 ..）
@@ -97,16 +146,12 @@ func (s *Server) handleServeUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	filename := chi.URLParam(r, "filename")
-	if filename == "" || strings.Contains(filename, "..") || strings.ContainsRune(filename, '/') {
+	absPath, ok := s.uploadPath(filename)
+	if !ok {
 		http.Error(w, "invalid path", http.StatusBadRequest)
 		return
 	}
-	absPath := filepath.Join(s.UploadDir, filename)
-	// 确保解析后的路径仍在 UploadDir 内，防止路径穿越
-	uploadDirAbs, _ := filepath.Abs(s.UploadDir)
-	absPath, _ = filepath.Abs(absPath)
-	sep := string(filepath.Separator)
-	if absPath != uploadDirAbs && !strings.HasPrefix(absPath, uploadDirAbs+sep) {
+	if !canUseUpload(caller(r.Context()), absPath) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -125,13 +170,98 @@ func (s *Server) handleServeUpload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	ct := "image/jpeg"
-	switch strings.ToLower(filepath.Ext(filename)) {
-	case ".png":
-		ct = "image/png"
-	case ".webp":
-		ct = "image/webp"
+	// 类型以上传时识别并保存的元信息为准；无元信息（如处理后的派生图）时按文件头识别，只提供图片
+	var ct string
+	if meta, err := media.ReadMeta(absPath); err == nil {
+		ct = meta.MIME
+	} else {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		ct = http.DetectContentType(head[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !strings.HasPrefix(ct, "image/") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
 	}
 	w.Header().Set("Content-Type", ct)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	http.ServeContent(w, r, filename, info.ModTime(), f)
 }
+
+// MediaProcessor 上传图片校验与预处理
+type MediaProcessor interface {
+	// Inspect 按文件内容识别并校验上传图片
+	Inspect(data []byte) (*media.Meta, error)
+	// ProcessRegion 原图路径 + 可选区域 → 送入模型的图片路径
+	ProcessRegion(srcPath string, region *media.Region) (string, error)
+}
+
+var (
+	errInvalidPath    = errors.New("invalid path")
+	errUploadNotFound = errors.New("upload not found")
+)
+
+// canUseUpload 调用者是否可使用该上传图片：管理员可用全部，其余用户只能用自己上传过的原图（派生图无元信息，仅管理员可见）
+func canUseUpload(p principal, absPath string) bool {
+	if p.Admin {
+		return true
+	}
+	meta, err := media.ReadMeta(absPath)
+	return err == nil && meta.HasOwner(p.UserID)
+}
+
+// modelImagePath 将上传文件名转为送入识图/解析模型的图片路径：校验路径与调用者权限后做预处理并按 region 裁剪。
+// 整图预处理失败时退回原图；指定了 region 时裁剪失败直接返回错误。
+func (s *Server) modelImagePath(ctx context.Context, filename string, region *media.Region) (string, error) {
+	absPath, ok := s.uploadPath(filename)
+	if !ok {
+		return "", errInvalidPath
+	}
+	if !canUseUpload(caller(ctx), absPath) {
+		return "", errUploadNotFound
+	}
+	if region != nil {
+		if err := region.Validate(); err != nil {
+			return "", err
+		}
+		if region.IsZero() {
+			region = nil
+		}
+	}
+	if s.Media == nil {
+		if region != nil {
+			return "", errors.New("region not supported: image preprocessing not configured")
+		}
+		return absPath, nil
+	}
+	processed, err := s.Media.ProcessRegion(absPath, region)
+	if err != nil {
+		if region != nil {
+			return "", fmt.Errorf("crop image: %w", err)
+		}
+		log.Printf("[media] preprocess %s: %v, using original", filename, err)
+		return absPath, nil
+	}
+	return processed, nil
+}
+
+// uploadPath 将上传文件名转为 UploadDir 下的绝对路径；filename 仅允许单级路径（无 / 与 ..），防止路径穿越
+func (s *Server) uploadPath(filename string) (string, bool) {
+	if filename == "" || strings.Contains(filename, "..") || strings.ContainsRune(filename, '/') {
+		return "", false
+	}
+	uploadDirAbs, err := filepath.Abs(s.UploadDir)
+	if err != nil {
+		return "", false
+	}
+	absPath := filepath.Join(uploadDirAbs, filename)
+	// 确保解析后的路径仍在 UploadDir 内
+	if !strings.HasPrefix(absPath, uploadDirAbs+string(filepath.Separator)) {
+		return "", false
+	}
+	return absPath, true
+}
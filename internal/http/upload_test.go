@@ -0,0 +1,61 @@
+package http
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoreUploadConcurrentOwners(t *testing.T) {
+	s, _ := newTestServer(t, &stubExplainer{})
+	data := testPNG(t)
+	owners := []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"}
+	// 多轮并发上传同一图片，每轮换一个目录，确保元信息都是从缺失开始创建
+	for round := 0; round < 50; round++ {
+		s.UploadDir = t.TempDir()
+		paths := make([]string, len(owners))
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i, owner := range owners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				resp, err := s.storeUpload(owner, "a.png", data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if resp.Duplicate {
+					t.Errorf("%s: duplicate = true for first upload by this user", owner)
+				}
+				paths[i] = resp.Path
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if t.Failed() {
+			t.FailNow()
+		}
+		if paths[0] != paths[len(paths)-1] {
+			t.Fatalf("same image stored as %q and %q", paths[0], paths[len(paths)-1])
+		}
+		abs := filepath.Join(s.UploadDir, paths[0])
+		for _, owner := range owners {
+			if !canUseUpload(principal{UserID: owner}, abs) {
+				t.Fatalf("round %d: %s cannot use own upload", round, owner)
+			}
+		}
+		if canUseUpload(principal{UserID: "other"}, abs) {
+			t.Fatal("other user can use the upload")
+		}
+	}
+	// 同一用户再次上传算重复
+	resp, err := s.storeUpload("u1", "a.png", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Duplicate {
+		t.Error("repeat upload by same user: duplicate = false")
+	}
+}
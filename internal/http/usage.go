@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomath/gomath/internal/usage"
+)
+
+// UsageLedger 模型调用用量台账
+type UsageLedger interface {
+	usage.Sink
+	Aggregate(q usage.Query) usage.Report
+}
+
+// withUsage 在请求 context 上挂载用量收集器，期间的模型调用记入台账（记在调用者名下）并汇总到返回的 Recorder
+func (s *Server) withUsage(ctx context.Context, endpoint string) (context.Context, *usage.Recorder) {
+	var sink usage.Sink
+	if s.Usage != nil {
+		sink = s.Usage
+	}
+	return usage.WithRecorder(ctx, sink, endpoint, caller(ctx).UserID)
+}
+
+// handleUsage 用量统计：from/to 为日期（YYYY-MM-DD，含 to 当天），group_by 为 day、model、endpoint 的逗号组合，默认三者全部。
+// 普通用户只能看到自己的用量，管理员可用 user_id 查看某个用户。
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if s.Usage == nil {
+		http.Error(w, "usage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := usage.Query{GroupBy: []string{"day", "model", "endpoint"}}
+	if p := caller(r.Context()); p.Admin {
+		q.UserID = r.URL.Query().Get("user_id")
+	} else {
+		q.UserID = p.UserID
+	}
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if q.From, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			http.Error(w, "invalid from, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if q.To, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			http.Error(w, "invalid to, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		q.To = q.To.AddDate(0, 0, 1)
+	}
+	if v := r.URL.Query().Get("group_by"); v != "" {
+		q.GroupBy = nil
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if !usage.ValidGroupBy(f) {
+				http.Error(w, "group_by must be a combination of day, model, endpoint", http.StatusBadRequest)
+				return
+			}
+			q.GroupBy = append(q.GroupBy, f)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.Usage.Aggregate(q))
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+)
+
+// HistoryPreferredRequest 选择当前采用的解析版本
+type HistoryPreferredRequest struct {
+	VersionID string `json:"version_id"`
+}
+
+// VersionDiffResponse 两个解析版本的逐步对比
+type VersionDiffResponse struct {
+	From          string                 `json:"from"` // 旧版本 ID
+	To            string                 `json:"to"`   // 新版本 ID
+	Steps         []explanation.StepDiff `json:"steps"`
+	AnswerFrom    string                 `json:"answer_from,omitempty"`
+	AnswerTo      string                 `json:"answer_to,omitempty"`
+	AnswerChanged bool                   `json:"answer_changed"`
+}
+
+// HistoryRegenerateRequest 重新生成的可选参数，请求体可为空
+type HistoryRegenerateRequest struct {
+	Style string `json:"style,omitempty"` // 解析风格：concise、detailed，为空时默认风格
+}
+
+// handleHistoryRegenerate 忽略缓存重新解析一条历史，可指定解析风格；新结果作为新版本并设为当前版本，返回同 POST /api/explain。
+// 各版本记录生成时的模型标识（含风格），更换配置中的模型或 prompt 版本后重新生成同样得到对应的新版本
+func (s *Server) handleHistoryRegenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req HistoryRegenerateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	s.serveExplain(w, r, ExplainRequest{HistoryID: chi.URLParam(r, "id"), Regenerate: true, Style: req.Style})
+}
+
+// handleHistoryPreferred 选择一条历史当前采用的解析版本（列表、卷面与检索均使用当前版本）
+func (s *Server) handleHistoryPreferred(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req HistoryPreferredRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if it, ok := s.HistoryStore.Get(id); !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !s.HistoryStore.SetPreferred(id, req.VersionID) {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHistoryDiff 对比一条历史的两个解析版本：to 默认为当前版本，from 默认为 to 的上一个版本
+func (s *Server) handleHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	it, ok := s.HistoryStore.Get(chi.URLParam(r, "id"))
+	if !ok || !caller(r.Context()).canAccess(it.UserID) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	toID := r.URL.Query().Get("to")
+	if toID == "" {
+		toID = it.Preferred
+	}
+	to := it.Version(toID)
+	if to == nil {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+	from := previousVersion(it, toID)
+	if id := r.URL.Query().Get("from"); id != "" {
+		from = it.Version(id)
+		if from == nil {
+			http.Error(w, "version not found", http.StatusNotFound)
+			return
+		}
+	}
+	if from == nil {
+		http.Error(w, "no earlier version to compare", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VersionDiffResponse{
+		From:          from.ID,
+		To:            to.ID,
+		Steps:         explanation.DiffSteps(from.Result.Steps, to.Result.Steps),
+		AnswerFrom:    from.Result.Answer,
+		AnswerTo:      to.Result.Answer,
+		AnswerChanged: explanation.NormalizeProblem(from.Result.Answer) != explanation.NormalizeProblem(to.Result.Answer),
+	})
+}
+
+// previousVersion 返回 versionID 的上一个版本，不存在时返回 nil
+func previousVersion(it *history.Item, versionID string) *history.Version {
+	for i := 1; i < len(it.Versions); i++ {
+		if it.Versions[i].ID == versionID {
+			return &it.Versions[i-1]
+		}
+	}
+	return nil
+}
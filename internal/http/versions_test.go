@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gomath/gomath/internal/cache"
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/explanation"
+	"github.com/gomath/gomath/internal/history"
+)
+
+// styleExplainer 按 context 上的解析风格生成，记录每次调用的风格
+type styleExplainer struct {
+	stubExplainer
+	mu     sync.Mutex
+	styles []string
+}
+
+func (g *styleExplainer) Fingerprint() string { return "stub|m|v1" }
+
+func (g *styleExplainer) Generate(ctx context.Context, problemText string) (*explanation.Result, error) {
+	style := explanation.StyleFrom(ctx)
+	g.mu.Lock()
+	g.styles = append(g.styles, style)
+	g.mu.Unlock()
+	return &explanation.Result{Problem: problemText, Answer: "x = 3 (" + style + ")"}, nil
+}
+
+func TestHistoryRegenerateStyle(t *testing.T) {
+	gen := &styleExplainer{}
+	s, hist := newTestServer(t, gen)
+	c, err := cache.NewStore("", config.CacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Cache = c
+	id := hist.Add(history.Item{Type: "text", Text: "2x + 4 = 10"})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rr
+	}
+	if rr := post("/api/explain", `{"history_id":"`+id+`"}`); rr.Code != http.StatusOK {
+		t.Fatalf("explain: %d %s", rr.Code, rr.Body)
+	}
+	regenerate := "/api/history/" + id + "/regenerate"
+	if rr := post(regenerate, `{"style":"concise"}`); rr.Code != http.StatusOK {
+		t.Fatalf("regenerate concise: %d %s", rr.Code, rr.Body)
+	}
+	if rr := post(regenerate, ""); rr.Code != http.StatusOK {
+		t.Fatalf("regenerate without body: %d %s", rr.Code, rr.Body)
+	}
+	if rr := post(regenerate, `{"style":"funny"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown style: status = %d, want 400", rr.Code)
+	}
+
+	it, _ := hist.Get(id)
+	if len(it.Versions) != 3 {
+		t.Fatalf("versions = %d, want 3", len(it.Versions))
+	}
+	var models []string
+	for _, v := range it.Versions {
+		models = append(models, v.Model)
+	}
+	if want := "stub|m|v1,stub|m|v1+concise,stub|m|v1"; strings.Join(models, ",") != want {
+		t.Errorf("version models = %v, want %s", models, want)
+	}
+	if got := it.Versions[1].Result.Answer; got != "x = 3 (concise)" {
+		t.Errorf("concise version answer = %q", got)
+	}
+	if got := strings.Join(gen.styles, ","); got != ",concise," {
+		t.Errorf("generator styles = %q", got)
+	}
+
+	// 风格不同的结果分开缓存：默认风格仍命中默认风格的结果
+	rr := post("/api/explain", `{"problem_text":"2x + 4 = 10"}`)
+	var resp ExplainResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := s.ExplainStore.Get(resp.TaskID)
+	if !resp.Cached || res == nil || res.Answer != "x = 3 ()" {
+		t.Errorf("default style after concise regenerate: cached = %v, result = %+v", resp.Cached, res)
+	}
+}
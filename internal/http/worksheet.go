@@ -0,0 +1,160 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gomath/gomath/internal/export"
+	"github.com/gomath/gomath/internal/history"
+)
+
+const (
+	maxWorksheetProblems  = 100
+	defaultWorksheetSpace = 4 // 默认每题留白厘米数
+	maxWorksheetSpace     = 30
+)
+
+// worksheetOptions 练习卷参数
+type worksheetOptions struct {
+	ids       []string
+	tag       string
+	title     string
+	answers   bool
+	numbering bool
+	space     int
+	shuffle   bool
+	seed      int64
+}
+
+// parseWorksheetOptions 解析练习卷参数：ids（逗号分隔，按给定顺序）与 tag（该标签的全部历史，旧在前）二选一，
+// answers 答案卷，numbering 题号（默认 true），space 留白厘米数（默认 4），shuffle 打乱题序，seed 打乱种子
+func parseWorksheetOptions(v url.Values) (worksheetOptions, error) {
+	o := worksheetOptions{tag: strings.TrimSpace(v.Get("tag")), title: strings.TrimSpace(v.Get("title")), numbering: true, space: defaultWorksheetSpace}
+	for _, id := range strings.Split(v.Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			o.ids = append(o.ids, id)
+		}
+	}
+	if (len(o.ids) == 0) == (o.tag == "") {
+		return o, errors.New("provide either ids or tag")
+	}
+	if len(o.ids) > maxWorksheetProblems {
+		return o, fmt.Errorf("at most %d problems per worksheet", maxWorksheetProblems)
+	}
+	if o.title == "" {
+		o.title = "练习卷"
+	}
+	for name, dst := range map[string]*bool{"answers": &o.answers, "numbering": &o.numbering, "shuffle": &o.shuffle} {
+		if b := v.Get(name); b != "" {
+			val, err := strconv.ParseBool(b)
+			if err != nil {
+				return o, fmt.Errorf("%s must be true or false", name)
+			}
+			*dst = val
+		}
+	}
+	if sp := v.Get("space"); sp != "" {
+		n, err := strconv.Atoi(sp)
+		if err != nil || n < 0 || n > maxWorksheetSpace {
+			return o, fmt.Errorf("space must be 0-%d (cm)", maxWorksheetSpace)
+		}
+		o.space = n
+	}
+	if sd := v.Get("seed"); sd != "" {
+		n, err := strconv.ParseInt(sd, 10, 64)
+		if err != nil {
+			return o, errors.New("seed must be an integer")
+		}
+		o.seed = n
+	} else if o.shuffle {
+		// 未指定种子时随机生成，并印在卷面上，生成答案卷时传入同一种子即可得到相同题序
+		o.seed = rand.Int63n(1000000)
+	}
+	return o, nil
+}
+
+// handleHistoryWorksheet 由选定的历史生成可打印的 HTML 练习卷（仅题目 + 作答留白）或答案卷（附解析与答案）：
+// GET /history/worksheet?ids=a,b|tag=x&answers=&numbering=&space=&shuffle=&seed=&title=
+func (s *Server) handleHistoryWorksheet(w http.ResponseWriter, r *http.Request) {
+	if s.HistoryStore == nil {
+		http.Error(w, "history not configured", http.StatusServiceUnavailable)
+		return
+	}
+	o, err := parseWorksheetOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := caller(r.Context())
+	var items []history.Item
+	if o.tag != "" {
+		items, err = s.allHistory(history.Query{UserID: p.scope(), Tags: []string{o.tag}, Oldest: true})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(items) > maxWorksheetProblems {
+			http.Error(w, fmt.Sprintf("tag has more than %d items", maxWorksheetProblems), http.StatusBadRequest)
+			return
+		}
+	} else {
+		for _, id := range o.ids {
+			it, ok := s.HistoryStore.Get(id)
+			if !ok || !p.canAccess(it.UserID) {
+				http.Error(w, "history item not found: "+id, http.StatusNotFound)
+				return
+			}
+			items = append(items, *it)
+		}
+	}
+	if len(items) == 0 {
+		http.Error(w, "no history items", http.StatusNotFound)
+		return
+	}
+
+	ws := &export.Worksheet{Title: o.title, Answers: o.answers, Numbering: o.numbering, SpaceCM: o.space}
+	if o.answers {
+		ws.Title += "（答案）"
+	}
+	for i := range items {
+		ws.Problems = append(ws.Problems, s.worksheetProblem(r, &items[i], o.answers))
+	}
+	if o.shuffle {
+		ws.Shuffle(o.seed)
+		ws.Subtitle = fmt.Sprintf("题序编号：%d", o.seed)
+		w.Header().Set("X-Worksheet-Seed", strconv.FormatInt(o.seed, 10))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(export.WorksheetHTML(ws))
+}
+
+// worksheetProblem 历史条目转为卷面上的一道题：有题目文字时用文字（多题图片拆出的题也只印本题），
+// 否则内嵌题目图片（按选定区域裁剪）；答案卷附历史中的解析与最终答案
+func (s *Server) worksheetProblem(r *http.Request, it *history.Item, answers bool) export.Document {
+	doc := export.Document{Problem: it.Text}
+	if strings.TrimSpace(it.Text) == "" && it.Path != "" {
+		if absPath, err := s.modelImagePath(r.Context(), it.Path, it.Region); err == nil {
+			doc.ProblemImage = s.exportFileImage(absPath)
+		}
+	}
+	if !answers {
+		return doc
+	}
+	if it.Result != nil {
+		for _, st := range it.Result.Steps {
+			img := st.ImageURL
+			if name, ok := localUploadName(img); ok {
+				img = s.exportImage(r, name, true)
+			}
+			doc.Steps = append(doc.Steps, export.Step{Title: st.Title, Content: st.Content, Image: img})
+		}
+		doc.Answer = it.Result.Answer
+	}
+	return doc
+}
@@ -0,0 +1,395 @@
+package mathcheck
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Statement 一步推导：单个表达式，或若干方程的析取（如 "x=2 或 x=3"）
+type Statement struct {
+	Exprs     []*Expr // 表达式时为单个表达式；方程时为各方程的 左边-右边
+	Equation  bool
+	variables []string
+}
+
+// 析取连接词：或 / or / 逗号 / 分号
+var disjunctionRe = regexp.MustCompile(`\s*(?:或者|或|\bor\b|,|;)\s*`)
+
+// ParseStatement 解析一步推导。含 "=" 时视为方程，多个方程可用「或」、逗号连接。
+func ParseStatement(s string) (*Statement, error) {
+	norm, err := normalize(s)
+	if err != nil {
+		return nil, err
+	}
+	parts := disjunctionRe.Split(norm, -1)
+	st := &Statement{}
+	vars := make(map[string]bool)
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty statement")
+		}
+		sides := strings.Split(part, "=")
+		isEq := len(sides) == 2
+		if len(sides) > 2 {
+			return nil, fmt.Errorf("chained equation not supported")
+		}
+		if i == 0 {
+			st.Equation = isEq
+		} else if st.Equation != isEq || !isEq {
+			return nil, fmt.Errorf("mixed statement not supported")
+		}
+		var e *Expr
+		if isEq {
+			e, err = parseEquation(sides[0], sides[1])
+		} else {
+			e, err = parseNormalized(part)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for v := range e.vars {
+			vars[v] = true
+		}
+		st.Exprs = append(st.Exprs, e)
+	}
+	for v := range vars {
+		st.variables = append(st.variables, v)
+	}
+	sort.Strings(st.variables)
+	return st, nil
+}
+
+// parseEquation 将 lhs = rhs 转为 lhs - rhs
+func parseEquation(lhs, rhs string) (*Expr, error) {
+	l, err := parseNormalized(lhs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := parseNormalized(rhs)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]bool)
+	for v := range l.vars {
+		vars[v] = true
+	}
+	for v := range r.vars {
+		vars[v] = true
+	}
+	return &Expr{eval: func(v map[string]float64) float64 { return l.eval(v) - r.eval(v) }, vars: vars}, nil
+}
+
+// value 表达式取值；方程析取时取各方程 (左-右) 之积，零点集即解集
+func (st *Statement) value(vars map[string]float64) float64 {
+	if !st.Equation {
+		return st.Exprs[0].eval(vars)
+	}
+	prod := 1.0
+	for _, e := range st.Exprs {
+		prod *= e.eval(vars)
+	}
+	return prod
+}
+
+// Verdict 本地等价性检查结论
+type Verdict struct {
+	Checked    bool   // false 表示无法本地判断（解析失败、类型不同等），应交由模型判断
+	Equivalent bool   // Checked 为 true 时有效
+	Subset     bool   // 不等价但解集是上一步解集的非空子集，如分情况讨论时取其中一种情况
+	Reason     string // 简要说明
+}
+
+// CheckStep 检查 next 是否可由 prev 等价变形得到：
+// 表达式比较取值是否处处相等；方程比较解集是否一致（单变量求根比较，多变量检查 左-右 是否成比例）。
+// next 本身为恒等式时也视为正确；单变量方程的解集为上一步解集的子集时标记 Subset（分情况讨论，不一定是错误）。
+func CheckStep(prev, next string) Verdict {
+	b, err := ParseStatement(next)
+	if err != nil {
+		return Verdict{Reason: "无法解析：" + err.Error()}
+	}
+	if b.Equation && len(b.Exprs) == 1 && isIdentity(b) {
+		return Verdict{Checked: true, Equivalent: true, Reason: "恒等式成立"}
+	}
+	a, err := ParseStatement(prev)
+	if err != nil {
+		return Verdict{Reason: "无法解析上一步：" + err.Error()}
+	}
+	if a.Equation != b.Equation {
+		return Verdict{Reason: "表达式与方程无法直接比较"}
+	}
+	vars := unionVars(a.variables, b.variables)
+	if !a.Equation {
+		if sameValues(a, b, vars) {
+			return Verdict{Checked: true, Equivalent: true, Reason: "表达式取值一致"}
+		}
+		return Verdict{Checked: true, Equivalent: false, Reason: "变形前后表达式取值不同"}
+	}
+	if len(vars) == 1 {
+		ra, okA := roots(a, vars[0])
+		rb, okB := roots(b, vars[0])
+		if okA && okB && (len(ra) > 0 || len(rb) > 0) {
+			if sameRoots(ra, rb) {
+				return Verdict{Checked: true, Equivalent: true, Reason: "解集一致"}
+			}
+			if len(rb) > 0 && subsetRoots(rb, ra) {
+				return Verdict{Checked: true, Subset: true, Reason: fmt.Sprintf("解集为上一步的一部分：%s vs %s", formatRoots(vars[0], ra), formatRoots(vars[0], rb))}
+			}
+			return Verdict{Checked: true, Equivalent: false, Reason: fmt.Sprintf("解集不一致：%s vs %s", formatRoots(vars[0], ra), formatRoots(vars[0], rb))}
+		}
+	}
+	if proportional(a, b, vars) {
+		return Verdict{Checked: true, Equivalent: true, Reason: "方程同解变形"}
+	}
+	// 非比例的多变量方程可能仍等价（如平方、取倒数），不下结论
+	return Verdict{Reason: "无法本地判断方程是否同解"}
+}
+
+func unionVars(a, b []string) []string {
+	set := make(map[string]bool)
+	for _, v := range a {
+		set[v] = true
+	}
+	for _, v := range b {
+		set[v] = true
+	}
+	out := make([]string, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+const samplePoints = 24
+
+// samples 生成固定种子的随机取值点，保证同一输入结论稳定
+func samples(vars []string) []map[string]float64 {
+	rng := rand.New(rand.NewSource(7))
+	out := make([]map[string]float64, samplePoints)
+	for i := range out {
+		m := make(map[string]float64, len(vars))
+		for _, v := range vars {
+			m[v] = rng.Float64()*6 - 3
+		}
+		out[i] = m
+	}
+	return out
+}
+
+func finite(x float64) bool { return !math.IsNaN(x) && !math.IsInf(x, 0) }
+
+func approxEqual(x, y float64) bool {
+	return math.Abs(x-y) <= 1e-7*math.Max(1, math.Max(math.Abs(x), math.Abs(y)))
+}
+
+func sameValues(a, b *Statement, vars []string) bool {
+	n := 0
+	for _, pt := range samples(vars) {
+		x, y := a.value(pt), b.value(pt)
+		if !finite(x) || !finite(y) {
+			continue
+		}
+		if !approxEqual(x, y) {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+func isIdentity(st *Statement) bool {
+	n := 0
+	for _, pt := range samples(st.variables) {
+		x := st.value(pt)
+		if !finite(x) {
+			continue
+		}
+		if math.Abs(x) > 1e-9 {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+// proportional 判断两方程的 左-右 是否处处成非零常数比例（移项、两边同乘除非零数等同解变形）
+func proportional(a, b *Statement, vars []string) bool {
+	ratio := math.NaN()
+	n := 0
+	for _, pt := range samples(vars) {
+		x, y := a.value(pt), b.value(pt)
+		if !finite(x) || !finite(y) || math.Abs(x) < 1e-9 {
+			continue
+		}
+		r := y / x
+		if math.Abs(r) < 1e-12 {
+			return false
+		}
+		if math.IsNaN(ratio) {
+			ratio = r
+		} else if !approxEqual(r, ratio) {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+const (
+	rootMin  = -100.0
+	rootMax  = 100.0
+	rootGrid = 8000
+)
+
+// roots 在 [rootMin, rootMax] 内数值求 value(x)=0 的根（变号二分 + |f| 局部极小处的重根），
+// 第二个返回值为 false 表示函数在区间内大面积无定义、无法可靠求根
+func roots(st *Statement, v string) ([]float64, bool) {
+	f := func(x float64) float64 { return st.value(map[string]float64{v: x}) }
+	step := (rootMax - rootMin) / rootGrid
+	xs := make([]float64, rootGrid+1)
+	ys := make([]float64, rootGrid+1)
+	defined := 0
+	for i := range xs {
+		xs[i] = rootMin + float64(i)*step
+		ys[i] = f(xs[i])
+		if finite(ys[i]) {
+			defined++
+		}
+	}
+	if defined < rootGrid/10 {
+		return nil, false
+	}
+	var out []float64
+	add := func(r float64) {
+		if len(out) > 0 && math.Abs(out[len(out)-1]-r) < 1e-5 {
+			return
+		}
+		out = append(out, r)
+	}
+	for i := 0; i <= rootGrid; i++ {
+		y := ys[i]
+		if !finite(y) {
+			continue
+		}
+		if y == 0 {
+			add(xs[i])
+			continue
+		}
+		if i+1 <= rootGrid && finite(ys[i+1]) && ys[i+1] != 0 && (y < 0) != (ys[i+1] < 0) {
+			if r, ok := bisect(f, xs[i], xs[i+1]); ok {
+				add(r)
+			}
+			continue
+		}
+		// 不变号的重根：|f| 在该点取局部极小且足够接近 0
+		if i > 0 && i < rootGrid && finite(ys[i-1]) && finite(ys[i+1]) &&
+			math.Abs(y) < math.Abs(ys[i-1]) && math.Abs(y) <= math.Abs(ys[i+1]) && (y < 0) == (ys[i+1] < 0) && (y < 0) == (ys[i-1] < 0) {
+			if r, ok := minimizeAbs(f, xs[i-1], xs[i+1]); ok {
+				add(r)
+			}
+		}
+	}
+	return out, true
+}
+
+// bisect 变号区间二分求根；区间内有间断（如 1/x）时 |f| 不收敛到 0，返回 false
+func bisect(f func(float64) float64, lo, hi float64) (float64, bool) {
+	flo := f(lo)
+	for i := 0; i < 80; i++ {
+		mid := (lo + hi) / 2
+		fm := f(mid)
+		if !finite(fm) {
+			return 0, false
+		}
+		if fm == 0 {
+			return mid, true
+		}
+		if (fm < 0) == (flo < 0) {
+			lo, flo = mid, fm
+		} else {
+			hi = mid
+		}
+	}
+	r := (lo + hi) / 2
+	return r, math.Abs(f(r)) < 1e-6
+}
+
+// minimizeAbs 黄金分割求 |f| 极小点，极小值足够接近 0 时视为重根
+func minimizeAbs(f func(float64) float64, lo, hi float64) (float64, bool) {
+	const phi = 0.6180339887498949
+	g := func(x float64) float64 { return math.Abs(f(x)) }
+	a, b := lo, hi
+	c, d := b-phi*(b-a), a+phi*(b-a)
+	for i := 0; i < 100; i++ {
+		if g(c) < g(d) {
+			b = d
+		} else {
+			a = c
+		}
+		c, d = b-phi*(b-a), a+phi*(b-a)
+	}
+	r := (a + b) / 2
+	return r, g(r) < 1e-8
+}
+
+func sameRoots(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-4*math.Max(1, math.Abs(a[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// subsetRoots a 中的每个根都在 b 中
+func subsetRoots(a, b []float64) bool {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if math.Abs(x-y) <= 1e-4*math.Max(1, math.Abs(y)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func formatRoots(v string, rs []float64) string {
+	if len(rs) == 0 {
+		return "无解"
+	}
+	parts := make([]string, 0, len(rs))
+	for _, r := range rs {
+		parts = append(parts, fmt.Sprintf("%s=%.4g", v, r))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mathSegmentRe 匹配文本中的 $...$ 公式片段
+var mathSegmentRe = regexp.MustCompile(`\$\$?([^$]+)\$\$?`)
+
+// LastStatement 从题目等自然语言文本中取最后一个可解析的公式片段（如「解方程 $x^2-5x+6=0$」中的方程），找不到时返回空串
+func LastStatement(text string) string {
+	segs := mathSegmentRe.FindAllStringSubmatch(text, -1)
+	for i := len(segs) - 1; i >= 0; i-- {
+		if _, err := ParseStatement(segs[i][1]); err == nil {
+			return segs[i][1]
+		}
+	}
+	if _, err := ParseStatement(text); err == nil {
+		return text
+	}
+	return ""
+}
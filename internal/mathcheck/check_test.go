@@ -0,0 +1,85 @@
+package mathcheck
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	cases := []struct {
+		in   string
+		vars map[string]float64
+		want float64
+	}{
+		{"2x^2 - 3x + 1", map[string]float64{"x": 2}, 3},
+		{"(x-2)(x-3)", map[string]float64{"x": 5}, 6},
+		{`$\frac{x+1}{2}$`, map[string]float64{"x": 3}, 2},
+		{`\sqrt{16} \cdot 3`, nil, 12},
+		{`2\left(x+1\right)`, map[string]float64{"x": 1}, 4},
+		{"-x^2", map[string]float64{"x": 3}, -9},
+		{"2^-1", nil, 0.5},
+		{"sqrt(x)^2", map[string]float64{"x": 9}, 9},
+		{"xy", map[string]float64{"x": 2, "y": 5}, 10},
+		{"３×（x＋1）", map[string]float64{"x": 1}, 6},
+	}
+	for _, c := range cases {
+		e, err := ParseExpr(c.in)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", c.in, err)
+			continue
+		}
+		if got := e.Eval(c.vars); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("ParseExpr(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, in := range []string{"", "x +", "(x-1", `\int x dx`, "设 x 为"} {
+		if _, err := ParseExpr(in); err == nil {
+			t.Errorf("ParseExpr(%q): expected error", in)
+		}
+	}
+}
+
+func TestCheckStep(t *testing.T) {
+	cases := []struct {
+		prev, next string
+		checked    bool
+		equivalent bool
+		subset     bool
+	}{
+		{"x^2 - 5x + 6 = 0", "(x-2)(x-3) = 0", true, true, false},
+		{"(x-2)(x-3) = 0", "x = 2 或 x = 3", true, true, false},
+		{"(x-2)(x-3) = 0", "x = 2 或 x = -3", true, false, false},
+		{"(x-2)(x-3) = 0", "x = 2", true, false, true}, // 分情况讨论
+		{"(x-2)(x-3) = 0", "x - 2 = 0", true, false, true},
+		{"x = 2", "(x-2)(x-3) = 0", true, false, false},
+		{"2x + 4 = 10", "2x = 6", true, true, false},
+		{"2x = 6", "x = 3", true, true, false},
+		{"2x = 6", "x = 4", true, false, false},
+		{"(x-1)^2 = 0", "x = 1", true, true, false},
+		{"2(x+3)", "2x + 6", true, true, false},
+		{"2(x+3)", "2x + 3", true, false, false},
+		{"x + y = 3", "2x + 2y = 6", true, true, false},
+		{"x^2 - 5x + 6 = 0", "x^2 = 5x - 6", true, true, false},
+		{"anything", "2(x+1) = 2x + 2", true, true, false},
+		{"x^2 = 4", "x - 2", false, false, false},
+		{"设 $x$ 为正数", "x = 1", false, false, false},
+	}
+	for _, c := range cases {
+		v := CheckStep(c.prev, c.next)
+		if v.Checked != c.checked || (v.Checked && (v.Equivalent != c.equivalent || v.Subset != c.subset)) {
+			t.Errorf("CheckStep(%q, %q) = %+v, want checked=%v equivalent=%v subset=%v", c.prev, c.next, v, c.checked, c.equivalent, c.subset)
+		}
+	}
+}
+
+func TestLastStatement(t *testing.T) {
+	if got := LastStatement("求一元二次方程 $x^2 - 5x + 6 = 0$ 的解。"); got != "x^2 - 5x + 6 = 0" {
+		t.Errorf("LastStatement = %q", got)
+	}
+	if got := LastStatement("一个长方形的周长是多少？"); got != "" {
+		t.Errorf("LastStatement = %q, want empty", got)
+	}
+}
@@ -0,0 +1,399 @@
+package mathcheck
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr 解析后的表达式，可按变量取值求值
+type Expr struct {
+	eval func(vars map[string]float64) float64
+	vars map[string]bool
+}
+
+// Eval 按变量取值求值；未给出的变量按 0 处理
+func (e *Expr) Eval(vars map[string]float64) float64 {
+	return e.eval(vars)
+}
+
+// Vars 返回表达式中出现的变量名
+func (e *Expr) Vars() []string {
+	out := make([]string, 0, len(e.vars))
+	for v := range e.vars {
+		out = append(out, v)
+	}
+	return out
+}
+
+// ParseExpr 解析单个表达式，支持常见 LaTeX 写法（\frac、\sqrt、\cdot 等）与省略乘号（如 2x、3(x+1)）
+func ParseExpr(s string) (*Expr, error) {
+	norm, err := normalize(s)
+	if err != nil {
+		return nil, err
+	}
+	return parseNormalized(norm)
+}
+
+func parseNormalized(s string) (*Expr, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	p := &parser{toks: toks, vars: make(map[string]bool)}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return &Expr{eval: n, vars: p.vars}, nil
+}
+
+// latexReplacer LaTeX 命令与全角/Unicode 符号到纯文本运算符的映射
+var latexReplacer = strings.NewReplacer(
+	"$", "", `\left`, "", `\right`, "", `\displaystyle`, "",
+	`\cdot`, "*", `\times`, "*", `\div`, "/", `\pi`, "pi ",
+	`\sin`, "sin", `\cos`, "cos", `\tan`, "tan", `\ln`, "ln", `\log`, "log", `\exp`, "exp",
+	`\,`, "", `\;`, "", `\!`, "", `\:`, "", `\quad`, " ", `\qquad`, " ", `\ `, " ",
+	`\{`, "(", `\}`, ")", "{", "(", "}", ")", "[", "(", "]", ")",
+	"−", "-", "×", "*", "÷", "/", "·", "*", "²", "^2", "³", "^3", "π", "pi ",
+)
+
+// normalize 将 LaTeX 片段转为纯文本表达式；遇到不支持的命令或非数学文字时返回错误
+func normalize(s string) (string, error) {
+	s = strings.TrimSpace(ToHalfWidth(s))
+	var err error
+	if s, err = expandCommand(s, `\frac`, 2, func(a []string) string { return "((" + a[0] + ")/(" + a[1] + "))" }); err != nil {
+		return "", err
+	}
+	if s, err = expandCommand(s, `\dfrac`, 2, func(a []string) string { return "((" + a[0] + ")/(" + a[1] + "))" }); err != nil {
+		return "", err
+	}
+	if s, err = expandCommand(s, `\sqrt`, 1, func(a []string) string { return "sqrt(" + a[0] + ")" }); err != nil {
+		return "", err
+	}
+	s = latexReplacer.Replace(s)
+	if strings.ContainsRune(s, '\\') {
+		return "", fmt.Errorf("unsupported latex command in %q", s)
+	}
+	return s, nil
+}
+
+// ToHalfWidth 将全角字符（数字、字母、括号、运算符等，含全角空格）转为半角
+func ToHalfWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u3000':
+			return ' '
+		case r >= 0xFF01 && r <= 0xFF5E:
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+// expandCommand 将形如 \cmd{a}{b} 的命令按 build 展开，参数内可嵌套
+func expandCommand(s, cmd string, nargs int, build func([]string) string) (string, error) {
+	for {
+		i := strings.Index(s, cmd)
+		if i < 0 {
+			return s, nil
+		}
+		j := i + len(cmd)
+		// 避免 \frac 误匹配 \fracx 之类的更长命令
+		if j < len(s) && unicode.IsLetter(rune(s[j])) {
+			return "", fmt.Errorf("unsupported latex command near %q", s[i:])
+		}
+		args := make([]string, 0, nargs)
+		for k := 0; k < nargs; k++ {
+			for j < len(s) && s[j] == ' ' {
+				j++
+			}
+			if j >= len(s) {
+				return "", fmt.Errorf("%s: missing argument", cmd)
+			}
+			if s[j] != '{' {
+				// 单字符参数，如 \frac12
+				args = append(args, s[j:j+1])
+				j++
+				continue
+			}
+			depth, start := 0, j+1
+			end := -1
+			for ; j < len(s); j++ {
+				if s[j] == '{' {
+					depth++
+				} else if s[j] == '}' {
+					depth--
+					if depth == 0 {
+						end = j
+						break
+					}
+				}
+			}
+			if end < 0 {
+				return "", fmt.Errorf("%s: unbalanced braces", cmd)
+			}
+			args = append(args, s[start:end])
+			j = end + 1
+		}
+		s = s[:i] + build(args) + s[j:]
+	}
+}
+
+type tokenKind int
+
+const (
+	tokNum tokenKind = iota
+	tokIdent
+	tokFunc
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+}
+
+// 支持的函数名；其余字母串按单字母变量逐个切分（xy 视为 x*y）
+var funcs = map[string]func(float64) float64{
+	"sqrt": math.Sqrt, "sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+	"ln": math.Log, "log": math.Log10, "exp": math.Exp, "abs": math.Abs,
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case isDigit(c) || c == '.':
+			j := i
+			for j < len(rs) && (isDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			v, err := strconv.ParseFloat(string(rs[i:j]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q", string(rs[i:j]))
+			}
+			toks = append(toks, token{kind: tokNum, text: string(rs[i:j]), num: v})
+			i = j
+		case c < unicode.MaxASCII && unicode.IsLetter(c):
+			j := i
+			for j < len(rs) && rs[j] < unicode.MaxASCII && unicode.IsLetter(rs[j]) {
+				j++
+			}
+			word := string(rs[i:j])
+			for word != "" {
+				matched := false
+				for name := range funcs {
+					if strings.HasPrefix(word, name) {
+						toks = append(toks, token{kind: tokFunc, text: name})
+						word = word[len(name):]
+						matched = true
+						break
+					}
+				}
+				if matched {
+					continue
+				}
+				if strings.HasPrefix(word, "pi") {
+					toks = append(toks, token{kind: tokNum, text: "pi", num: math.Pi})
+					word = word[2:]
+					continue
+				}
+				toks = append(toks, token{kind: tokIdent, text: word[:1]})
+				word = word[1:]
+			}
+			i = j
+		case strings.ContainsRune("+-*/^", c):
+			toks = append(toks, token{kind: tokOp, text: string(c)})
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "("})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")"})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", string(c))
+		}
+	}
+	return toks, nil
+}
+
+func isDigit(c rune) bool { return c >= '0' && c <= '9' }
+
+type evalFunc = func(vars map[string]float64) float64
+
+type parser struct {
+	toks []token
+	pos  int
+	vars map[string]bool
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokOp && t.text == op
+}
+
+// expr := term (('+'|'-') term)*
+func (p *parser) expr() (evalFunc, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.toks[p.pos].text
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(v map[string]float64) float64 { return l(v) + right(v) }
+		} else {
+			left = func(v map[string]float64) float64 { return l(v) - right(v) }
+		}
+	}
+	return left, nil
+}
+
+// term := unary (('*'|'/') unary | 省略乘号 power)*
+func (p *parser) term() (evalFunc, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t == nil {
+			return left, nil
+		}
+		var op string
+		switch {
+		case t.kind == tokOp && (t.text == "*" || t.text == "/"):
+			op = t.text
+			p.pos++
+		case t.kind == tokNum || t.kind == tokIdent || t.kind == tokFunc || t.kind == tokLParen:
+			op = "*"
+		default:
+			return left, nil
+		}
+		var right evalFunc
+		if op == "*" && t.kind != tokOp {
+			right, err = p.power()
+		} else {
+			right, err = p.unary()
+		}
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(v map[string]float64) float64 { return l(v) * right(v) }
+		} else {
+			left = func(v map[string]float64) float64 { return l(v) / right(v) }
+		}
+	}
+}
+
+// unary := ('-'|'+') unary | power
+func (p *parser) unary() (evalFunc, error) {
+	if p.isOp("-") {
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v map[string]float64) float64 { return -x(v) }, nil
+	}
+	if p.isOp("+") {
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power := primary ('^' unary)?，右结合
+func (p *parser) power() (evalFunc, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		p.pos++
+		exp, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v map[string]float64) float64 { return math.Pow(base(v), exp(v)) }, nil
+	}
+	return base, nil
+}
+
+// primary := number | ident | func arg | '(' expr ')'
+func (p *parser) primary() (evalFunc, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	switch t.kind {
+	case tokNum:
+		p.pos++
+		n := t.num
+		return func(map[string]float64) float64 { return n }, nil
+	case tokIdent:
+		p.pos++
+		name := t.text
+		p.vars[name] = true
+		return func(v map[string]float64) float64 { return v[name] }, nil
+	case tokFunc:
+		p.pos++
+		f := funcs[t.text]
+		// sqrt(x)^2 中的指数作用于函数值；无括号时 sin x^2 按 sin(x^2)
+		var arg evalFunc
+		var err error
+		if next := p.peek(); next != nil && next.kind == tokLParen {
+			arg, err = p.primary()
+		} else {
+			arg, err = p.power()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func(v map[string]float64) float64 { return f(arg(v)) }, nil
+	case tokLParen:
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,214 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/persist"
+
+	_ "golang.org/x/image/webp" // 注册 WebP 解码
+)
+
+// derivedMarker 处理后派生图的文件名标记：<原名>.p-<参数摘要>.<扩展名>
+const derivedMarker = ".p-"
+
+// Processor 图片预处理：EXIF 方向校正、去除 EXIF/GPS（重编码即丢弃元数据）、长边缩放、可选灰度与对比度增强。
+// 处理结果按参数缓存在原图旁，原图更新后自动重新生成。
+type Processor struct {
+	cfg config.MediaConfig
+}
+
+// NewProcessor 根据统一配置中的 media 块创建
+func NewProcessor(cfg config.MediaConfig) *Processor {
+	return &Processor{cfg: cfg}
+}
+
+// Process 返回送入模型的整图路径：未启用时为原图，否则为（缓存的）处理后派生图
+func (p *Processor) Process(srcPath string) (string, error) {
+	return p.ProcessRegion(srcPath, nil)
+}
+
+// ProcessRegion 同 Process，并按 region 裁剪、旋转（region 为空表示整图）。
+// 裁剪与旋转总会执行，即使关闭了预处理；处理顺序为：方向校正 → 裁剪 → 旋转 → 缩放 → 灰度/对比度。
+func (p *Processor) ProcessRegion(srcPath string, region *Region) (string, error) {
+	if region != nil && region.IsZero() {
+		region = nil
+	}
+	if region != nil {
+		if err := region.Validate(); err != nil {
+			return "", err
+		}
+	}
+	if (p == nil || p.cfg.Disabled) && region == nil {
+		return srcPath, nil
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("decode image config: %w", err)
+	}
+	ext := ".jpg"
+	if format == "png" {
+		// 截图等 PNG 保持无损，避免文字边缘出现压缩噪点
+		ext = ".png"
+	}
+	key := p.key()
+	if region != nil {
+		key += "-" + region.key()
+	}
+	dst := DerivedPath(srcPath, key, ext)
+	if di, err := os.Stat(dst); err == nil && !di.ModTime().Before(info.ModTime()) {
+		return dst, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("decode image: %w", err)
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	if region != nil {
+		if region.HasCrop() {
+			img = crop(img, *region)
+		}
+		img = rotate(img, region.Rotate)
+	}
+	if p != nil && !p.cfg.Disabled {
+		img = downscale(img, p.cfg.MaxEdgePixels())
+		if p.cfg.Grayscale {
+			img = toGray(img)
+		}
+		img = boostContrast(img, p.cfg.Contrast)
+	}
+
+	var buf bytes.Buffer
+	if ext == ".png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.quality()})
+	}
+	if err != nil {
+		return "", fmt.Errorf("encode image: %w", err)
+	}
+	if err := persist.WriteFile(dst, buf.Bytes(), 0644, false); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// Inspect 按配置的尺寸限制校验上传图片，见包级函数 Inspect
+func (p *Processor) Inspect(data []byte) (*Meta, error) {
+	var cfg config.MediaConfig
+	if p != nil {
+		cfg = p.cfg
+	}
+	return Inspect(data, cfg)
+}
+
+// key 预处理参数摘要，参数变化时生成新的派生图
+func (p *Processor) key() string {
+	if p == nil || p.cfg.Disabled {
+		return "raw"
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("v1|%d|%d|%t|%.3f", p.cfg.MaxEdgePixels(), p.cfg.Quality(), p.cfg.Grayscale, p.cfg.Contrast)))
+	return hex.EncodeToString(sum[:4])
+}
+
+func (p *Processor) quality() int {
+	if p == nil {
+		return config.MediaConfig{}.Quality()
+	}
+	return p.cfg.Quality()
+}
+
+// DerivedPath 返回原图 srcPath 的派生图路径（与原图同目录）
+func DerivedPath(srcPath, key, ext string) string {
+	base := strings.TrimSuffix(srcPath, filepath.Ext(srcPath))
+	return base + derivedMarker + key + ext
+}
+
+// IsDerived 判断文件名是否为派生图
+func IsDerived(name string) bool {
+	return strings.Contains(filepath.Base(name), derivedMarker)
+}
+
+// DerivedOf 判断 name 是否为原图 original（均为文件名）的派生图
+func DerivedOf(name, original string) bool {
+	base := strings.TrimSuffix(filepath.Base(original), filepath.Ext(original))
+	return strings.HasPrefix(filepath.Base(name), base+derivedMarker)
+}
+
+// RemoveUpload 删除上传图片及其元信息与全部派生图
+func RemoveUpload(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(MetaPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if DerivedOf(e.Name(), path) {
+			if err := os.Remove(filepath.Join(filepath.Dir(path), e.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadBase64 读取图片并转为 base64，MIME 类型按文件内容识别
+func ReadBase64(imagePath string) (data string, mime string, err error) {
+	b, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), DetectMIME(b), nil
+}
+
+// HashBytes 返回内容的 SHA-256（十六进制），用作上传文件名与缓存键
+func HashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile 返回文件内容的 SHA-256（十六进制）
+func HashFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return HashBytes(b), nil
+}
+
+// DetectMIME 按文件头识别图片 MIME 类型，非图片时返回 image/png（与模型接口的默认约定一致）
+func DetectMIME(b []byte) string {
+	ct := http.DetectContentType(b)
+	if strings.HasPrefix(ct, "image/") {
+		return ct
+	}
+	return "image/png"
+}
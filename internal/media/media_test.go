@@ -0,0 +1,236 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+// jpegWithOrientation 生成 w×h 的 JPEG，并在 SOI 后插入带 Orientation 的 EXIF 段
+func jpegWithOrientation(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	// TIFF（大端）：头 8 字节 + IFD0 一项 Orientation
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1}
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	seg = append(seg, payload...)
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for _, o := range []int{1, 3, 6, 8} {
+		if got := jpegOrientation(jpegWithOrientation(t, 4, 2, o)); got != o {
+			t.Errorf("orientation = %d, want %d", got, o)
+		}
+	}
+	if got := jpegOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("orientation of non-jpeg = %d, want 1", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	marker := color.NRGBA{R: 255, A: 255}
+	src.Set(0, 1, marker) // 左下角
+	dst := orient(src, 6) // 顺时针 90°：左下角转到左上角
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+		t.Fatalf("bounds = %v, want 2x3", b)
+	}
+	if got := color.NRGBAModel.Convert(dst.At(0, 0)); got != marker {
+		t.Errorf("dst(0,0) = %v, want %v", got, marker)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(src, jpegWithOrientation(t, 400, 200, 6), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(config.MediaConfig{MaxEdge: 100})
+	out, err := p.Process(src)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if out == src || !DerivedOf(out, src) {
+		t.Fatalf("Process returned %q, want derived of %q", out, src)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 400x200 顺时针旋转后为 200x400，再缩放到长边 100
+	if cfg.Width != 50 || cfg.Height != 100 {
+		t.Errorf("processed size = %dx%d, want 50x100", cfg.Width, cfg.Height)
+	}
+	if bytes.Contains(data, []byte("Exif\x00\x00")) {
+		t.Error("processed image still contains EXIF")
+	}
+	again, err := p.Process(src)
+	if err != nil || again != out {
+		t.Errorf("second Process = %q, %v; want cached %q", again, err, out)
+	}
+	disabled := NewProcessor(config.MediaConfig{Disabled: true})
+	if got, _ := disabled.Process(src); got != src {
+		t.Errorf("disabled Process = %q, want original", got)
+	}
+}
+
+func TestProcessRegion(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "page.jpg")
+	if err := os.WriteFile(src, jpegWithOrientation(t, 400, 200, 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(config.MediaConfig{})
+	out, err := p.ProcessRegion(src, &Region{X: 0.5, Y: 0, W: 0.5, H: 0.5, Rotate: 90})
+	if err != nil {
+		t.Fatalf("ProcessRegion: %v", err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 右上 200x100 裁剪后顺时针旋转 90° 为 100x200
+	if cfg.Width != 100 || cfg.Height != 200 {
+		t.Errorf("region size = %dx%d, want 100x200", cfg.Width, cfg.Height)
+	}
+	whole, _ := p.Process(src)
+	if whole == out {
+		t.Error("region derivative should differ from whole-image derivative")
+	}
+	if _, err := p.ProcessRegion(src, &Region{X: 0.8, W: 0.5, H: 0.5}); err == nil {
+		t.Error("expected error for out-of-range region")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	data := jpegWithOrientation(t, 40, 20, 1)
+	meta, err := Inspect(data, config.MediaConfig{})
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if meta.MIME != "image/jpeg" || meta.Width != 40 || meta.Height != 20 || meta.Ext() != ".jpg" {
+		t.Errorf("meta = %+v", meta)
+	}
+	if !ExtMatches("a.JPEG", meta.MIME) || ExtMatches("a.png", meta.MIME) {
+		t.Error("ExtMatches mismatch")
+	}
+	if _, err := Inspect([]byte("%PDF-1.4 renamed pdf"), config.MediaConfig{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("pdf: err = %v, want ErrUnsupportedType", err)
+	}
+	if _, err := Inspect(data, config.MediaConfig{MaxDimension: 30}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("oversize: err = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := Inspect(data[:len(data)/2], config.MediaConfig{}); !errors.Is(err, ErrCorruptImage) {
+		t.Errorf("truncated: err = %v, want ErrCorruptImage", err)
+	}
+}
+
+func TestAddOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	meta := &Meta{MIME: "image/png", Width: 8, Height: 8, Size: 100}
+
+	// 元信息缺失时创建；未登录上传不记上传者
+	if added, err := AddOwner(path, meta, ""); err != nil || added {
+		t.Fatalf("AddOwner(\"\") = %v, %v; want false, nil", added, err)
+	}
+	m, err := ReadMeta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MIME != "image/png" || len(m.Owners) != 0 {
+		t.Fatalf("created meta = %+v", m)
+	}
+	if added, err := AddOwner(path, meta, "u1"); err != nil || !added {
+		t.Fatalf("first AddOwner(u1) = %v, %v; want true, nil", added, err)
+	}
+	if added, err := AddOwner(path, meta, "u1"); err != nil || added {
+		t.Fatalf("second AddOwner(u1) = %v, %v; want false, nil", added, err)
+	}
+	if meta.Owners != nil {
+		t.Errorf("caller's meta modified: %+v", meta)
+	}
+
+	// 元信息损坏时按 meta 重建
+	if err := os.WriteFile(MetaPath(path), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := AddOwner(path, meta, "u2"); err != nil || !added {
+		t.Fatalf("AddOwner on corrupt meta = %v, %v; want true, nil", added, err)
+	}
+	if m, err := ReadMeta(path); err != nil || !m.HasOwner("u2") || m.Width != 8 {
+		t.Fatalf("rebuilt meta = %+v, %v", m, err)
+	}
+}
+
+func TestAddOwnerConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	meta := &Meta{MIME: "image/png", Width: 8, Height: 8}
+	var want []string
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		owner := "u" + strconv.Itoa(i)
+		want = append(want, owner)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := AddOwner(path, meta, owner); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	m, err := ReadMeta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := append([]string(nil), m.Owners...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("owners = %v, want %v", got, want)
+	}
+}
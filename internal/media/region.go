@@ -0,0 +1,50 @@
+package media
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// Region 用户选定的图片区域，用于从整页中只取一道题：
+// X/Y/W/H 为相对（方向校正后）图片宽高的比例（0~1），左上角为原点，W、H 为 0 表示不裁剪；
+// Rotate 为裁剪后顺时针旋转的角度，用于摆正倾斜的题目。
+type Region struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w"`
+	H      float64 `json:"h"`
+	Rotate float64 `json:"rotate,omitempty"`
+}
+
+// HasCrop 是否需要裁剪
+func (r Region) HasCrop() bool {
+	return r.W > 0 || r.H > 0
+}
+
+// IsZero 既不裁剪也不旋转
+func (r Region) IsZero() bool {
+	return !r.HasCrop() && math.Mod(r.Rotate, 360) == 0
+}
+
+// Validate 检查坐标范围
+func (r Region) Validate() error {
+	const eps = 1e-6
+	if math.IsNaN(r.X+r.Y+r.W+r.H+r.Rotate) || math.IsInf(r.Rotate, 0) {
+		return fmt.Errorf("invalid region")
+	}
+	if !r.HasCrop() {
+		return nil
+	}
+	if r.X < 0 || r.Y < 0 || r.W <= 0 || r.H <= 0 || r.X+r.W > 1+eps || r.Y+r.H > 1+eps {
+		return fmt.Errorf("region must satisfy 0 <= x, y and 0 < w, h and x+w, y+h <= 1")
+	}
+	return nil
+}
+
+// key 区域摘要，用于区分不同区域的派生图
+func (r Region) key() string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%.4f|%.4f|%.4f|%.4f|%.2f", r.X, r.Y, r.W, r.H, r.Rotate)))
+	return hex.EncodeToString(sum[:4])
+}
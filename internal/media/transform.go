@@ -0,0 +1,165 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// downscale 等比缩小到长边不超过 maxEdge，已足够小时原样返回
+func downscale(src image.Image, maxEdge int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	long := w
+	if h > long {
+		long = h
+	}
+	if maxEdge <= 0 || long <= maxEdge {
+		return src
+	}
+	nw := w * maxEdge / long
+	nh := h * maxEdge / long
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// orient 按 EXIF Orientation 将图片转为正向（1 为正常，2~8 为翻转/旋转组合）
+func orient(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 先转为 RGBA（draw 对常见格式有快速路径），再逐像素复制 4 字节
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-dx, dy
+			case 3: // 旋转 180°
+				sx, sy = w-1-dx, h-1-dy
+			case 4: // 垂直翻转
+				sx, sy = dx, h-1-dy
+			case 5: // 转置
+				sx, sy = dy, dx
+			case 6: // 顺时针 90°
+				sx, sy = dy, h-1-dx
+			case 7: // 反转置
+				sx, sy = w-1-dy, h-1-dx
+			case 8: // 逆时针 90°
+				sx, sy = w-1-dy, dx
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], rgba.Pix[rgba.PixOffset(sx, sy):rgba.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// toGray 转为灰度图
+func toGray(src image.Image) *image.Gray {
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// boostContrast 以 128 为中心按系数拉伸对比度，factor ≤ 1 时不处理
+func boostContrast(src image.Image, factor float64) image.Image {
+	if factor <= 1 {
+		return src
+	}
+	var lut [256]uint8
+	for i := range lut {
+		v := (float64(i)-128)*factor + 128
+		if v < 0 {
+			v = 0
+		} else if v > 255 {
+			v = 255
+		}
+		lut[i] = uint8(v + 0.5)
+	}
+	if g, ok := src.(*image.Gray); ok {
+		for i, p := range g.Pix {
+			g.Pix[i] = lut[p]
+		}
+		return g
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(src.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			dst.SetNRGBA(x, y, color.NRGBA{R: lut[c.R], G: lut[c.G], B: lut[c.B], A: c.A})
+		}
+	}
+	return dst
+}
+
+// crop 按相对坐标裁剪
+func crop(src image.Image, r Region) image.Image {
+	b := src.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(r.X*float64(b.Dx())+0.5),
+		b.Min.Y+int(r.Y*float64(b.Dy())+0.5),
+		b.Min.X+int((r.X+r.W)*float64(b.Dx())+0.5),
+		b.Min.Y+int((r.Y+r.H)*float64(b.Dy())+0.5),
+	).Intersect(b)
+	if rect.Empty() {
+		return src
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// rotate 顺时针旋转任意角度：90° 的整数倍无损处理，其余角度双线性插值并扩展画布，空白处填白色
+func rotate(src image.Image, deg float64) image.Image {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	switch {
+	case deg == 0:
+		return src
+	case deg == 90:
+		return orient(src, 6)
+	case deg == 180:
+		return orient(src, 3)
+	case deg == 270:
+		return orient(src, 8)
+	}
+	b := src.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	rad := deg * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	dw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+	dh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	// 源图中心旋转后对齐到目标图中心（y 轴向下时该矩阵即为顺时针旋转）
+	cx, cy := float64(b.Min.X)+w/2, float64(b.Min.Y)+h/2
+	s2d := f64.Aff3{
+		cos, -sin, float64(dw)/2 - cos*cx + sin*cy,
+		sin, cos, float64(dh)/2 - sin*cx - cos*cy,
+	}
+	draw.BiLinear.Transform(dst, s2d, src, b, draw.Over, nil)
+	return dst
+}
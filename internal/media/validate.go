@@ -0,0 +1,161 @@
+package media
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/persist"
+)
+
+var (
+	// ErrUnsupportedType 文件内容不是支持的图片格式（JPEG/PNG/WebP）
+	ErrUnsupportedType = errors.New("unsupported file type, use JPEG/PNG/WebP")
+	// ErrExtensionMismatch 文件扩展名与实际内容不符（如改名的 PDF）
+	ErrExtensionMismatch = errors.New("file extension does not match file content")
+	// ErrImageTooLarge 图片尺寸或像素数超出限制（防解压炸弹）
+	ErrImageTooLarge = errors.New("image dimensions too large")
+	// ErrCorruptImage 图片无法完整解码
+	ErrCorruptImage = errors.New("corrupt or truncated image")
+)
+
+// 支持的图片格式：MIME → 规范扩展名
+var imageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// 图片解码器格式名 → MIME
+var formatMIME = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// Meta 上传图片元信息，保存在原图旁的 <文件名>.meta.json
+type Meta struct {
+	MIME   string   `json:"mime"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Size   int64    `json:"size"`
+	Owners []string `json:"owners,omitempty"` // 上传过该图片的用户（同一内容只存一份），未开启鉴权时为空
+}
+
+// Ext 返回 MIME 对应的规范扩展名
+func (m *Meta) Ext() string {
+	return imageExts[m.MIME]
+}
+
+// Inspect 按文件头识别图片类型，先读取尺寸检查限制，再完整解码校验，返回元信息
+func Inspect(data []byte, limits config.MediaConfig) (*Meta, error) {
+	mime := http.DetectContentType(data)
+	if _, ok := imageExts[mime]; !ok {
+		return nil, ErrUnsupportedType
+	}
+	// 先只读头部尺寸，超限时不做完整解码，防止解压炸弹耗尽内存
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+	}
+	if formatMIME[format] != mime {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrCorruptImage
+	}
+	maxDim := limits.MaxDimensionPixels()
+	if cfg.Width > maxDim || cfg.Height > maxDim || int64(cfg.Width)*int64(cfg.Height) > limits.MaxPixelCount() {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+	}
+	return &Meta{MIME: mime, Width: cfg.Width, Height: cfg.Height, Size: int64(len(data))}, nil
+}
+
+// ExtMatches 判断客户端文件名的扩展名与识别出的类型是否一致；无扩展名时视为一致
+func ExtMatches(filename, mime string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return true
+	}
+	if ext == ".jpeg" {
+		ext = ".jpg"
+	}
+	return imageExts[mime] == ext
+}
+
+// MetaPath 返回图片元信息文件路径
+func MetaPath(imagePath string) string {
+	return imagePath + ".meta.json"
+}
+
+// IsMetaFile 判断文件名是否为元信息文件
+func IsMetaFile(name string) bool {
+	return strings.HasSuffix(name, ".meta.json")
+}
+
+// WriteMeta 将元信息写到图片旁
+func WriteMeta(imagePath string, m *Meta) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(MetaPath(imagePath), data, 0644, false)
+}
+
+// ReadMeta 读取图片旁的元信息
+func ReadMeta(imagePath string) (*Meta, error) {
+	data, err := os.ReadFile(MetaPath(imagePath))
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// metaMu 串行化元信息的读改写（同一图片可能被多个用户同时上传）
+var metaMu sync.Mutex
+
+// AddOwner 将 owner 记入图片元信息的上传用户列表，已存在时不重复添加；元信息缺失或损坏时按 m 创建。
+// owner 为空时只确保元信息存在。返回 owner 是否为新记入的上传者
+func AddOwner(imagePath string, m *Meta, owner string) (bool, error) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	existing, err := ReadMeta(imagePath)
+	if err != nil {
+		created := *m
+		created.Owners = nil
+		if owner != "" {
+			created.Owners = []string{owner}
+		}
+		return owner != "", WriteMeta(imagePath, &created)
+	}
+	if owner == "" || existing.HasOwner(owner) {
+		return false, nil
+	}
+	existing.Owners = append(existing.Owners, owner)
+	return true, WriteMeta(imagePath, existing)
+}
+
+// HasOwner 判断 owner 是否上传过该图片
+func (m *Meta) HasOwner(owner string) bool {
+	for _, o := range m.Owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
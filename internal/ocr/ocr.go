@@ -6,11 +6,13 @@ import (
 	"os"
 
 	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/ratelimit"
 )
 
 // Service 识图服务：图片 → 题目文本（含 LaTeX 公式），使用 OCR 配置块
 type Service struct {
-	cfg config.OCRConfig
+	cfg   config.OCRConfig
+	slots *ratelimit.Concurrency // 可选，上游并发限制
 }
 
 // NewService 根据统一配置中的 ocr 块创建识图服务
@@ -18,6 +20,16 @@ func NewService(cfg config.OCRConfig) *Service {
 	return &Service{cfg: cfg}
 }
 
+// SetConcurrency 设置上游并发限制（与解析共用同一上游时传入同一个实例）
+func (s *Service) SetConcurrency(c *ratelimit.Concurrency) {
+	s.slots = c
+}
+
+// Fingerprint 识图结果的模型标识（provider、model、prompt 版本），用于缓存键
+func (s *Service) Fingerprint() string {
+	return s.cfg.Provider + "|" + s.cfg.Model + "|" + PromptVersion
+}
+
 // Recognize 将图片转为题目文本。imagePath 为已上传文件的路径（绝对或相对 upload 目录）。
 // 返回的文本中公式应以 LaTeX 表示（如 $...$ / $$...$$），供后续解析与前端渲染。
 // 未配置 provider/model 时使用占位结果，便于联调；配置后调用真实多模态/视觉 API。
@@ -27,7 +39,7 @@ func (s *Service) Recognize(ctx context.Context, imagePath string) (string, erro
 	}
 	if s.cfg.Provider != "" && s.cfg.Model != "" {
 		// OpenAI 或兼容 OpenAI 的视觉 API（如 ops-ai-gateway、火山等）
-		text, err := callVisionAPI(ctx, s.cfg, imagePath)
+		text, err := callVisionAPI(ctx, s.cfg, s.slots, imagePath, visionPrompt)
 		if err != nil {
 			return "", fmt.Errorf("vision api: %w", err)
 		}
@@ -0,0 +1,116 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gomath/gomath/internal/explanation"
+)
+
+// 多题识别 prompt：按题切分并给出题号、题目文字与大致位置
+const problemsPrompt = `图片中可能包含多道数学题（如试卷照片），请逐题识别，并严格按以下 JSON 数组格式输出（不要其他前后文字），每题包含：
+- number: 题号（如 "1"、"2"、"(3)"，图片中没有题号时按出现顺序编号）
+- text: 该题完整题目文字，数学公式用 LaTeX（行内 $...$，独立公式 $$...$$）；不要添加解析或答案
+- bbox: 该题在图片中的大致区域 [x, y, w, h]，取值为相对图片宽高的比例（0~1），左上角为原点；无法确定时省略
+
+例如：
+[{"number":"1","text":"...","bbox":[0.05,0.1,0.9,0.2]},{"number":"2","text":"..."}]
+`
+
+// BBox 题目在图片中的区域，坐标为相对宽高的比例（0~1），左上角为原点
+type BBox struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Problem 图片中识别出的一道题
+type Problem struct {
+	Number string `json:"number"`
+	Text   string `json:"text"`
+	BBox   *BBox  `json:"bbox,omitempty"`
+}
+
+// RecognizeProblems 将可能含多道题的图片（如试卷照片）逐题识别。单题图片返回长度为 1 的列表。
+func (s *Service) RecognizeProblems(ctx context.Context, imagePath string) ([]Problem, error) {
+	if _, err := os.Stat(imagePath); err != nil {
+		return nil, fmt.Errorf("image file: %w", err)
+	}
+	if s.cfg.Provider == "" || s.cfg.Model == "" {
+		return recognizeProblemsStub(imagePath)
+	}
+	text, err := callVisionAPI(ctx, s.cfg, s.slots, imagePath, problemsPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("vision api: %w", err)
+	}
+	return parseProblems(text)
+}
+
+// rawProblem 模型输出：bbox 为 [x, y, w, h] 数组
+type rawProblem struct {
+	Number json.RawMessage `json:"number"`
+	Text   string          `json:"text"`
+	BBox   []float64       `json:"bbox"`
+}
+
+func parseProblems(text string) ([]Problem, error) {
+	text = explanation.ExtractJSON(strings.TrimSpace(text), "[", "]")
+	var raw []rawProblem
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, fmt.Errorf("parse problems: %w (response length %d)", err, len(text))
+	}
+	out := make([]Problem, 0, len(raw))
+	for i, rp := range raw {
+		if strings.TrimSpace(rp.Text) == "" {
+			continue
+		}
+		p := Problem{Number: parseNumber(rp.Number), Text: strings.TrimSpace(rp.Text)}
+		if p.Number == "" {
+			p.Number = strconv.Itoa(i + 1)
+		}
+		if b := rp.BBox; len(b) == 4 && b[2] > 0 && b[3] > 0 {
+			p.BBox = &BBox{X: clamp01(b[0]), Y: clamp01(b[1]), W: clamp01(b[2]), H: clamp01(b[3])}
+		}
+		out = append(out, p)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("parse problems: no problem recognized")
+	}
+	return out, nil
+}
+
+// parseNumber 题号可能被模型输出为字符串或数字
+func parseNumber(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	var n float64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return ""
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// recognizeProblemsStub 占位实现：返回两道示例题
+func recognizeProblemsStub(_ string) ([]Problem, error) {
+	return []Problem{
+		{Number: "1", Text: "求一元二次方程 $x^2 - 5x + 6 = 0$ 的解。", BBox: &BBox{X: 0.05, Y: 0.05, W: 0.9, H: 0.4}},
+		{Number: "2", Text: "已知 $2x + 4 = 10$，求 $x$ 的值。", BBox: &BBox{X: 0.05, Y: 0.5, W: 0.9, H: 0.4}},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 学生作答识别 prompt：逐步转写学生的解题过程，不做批改
+const solutionPrompt = `请识别图片中学生手写的解题过程，按书写顺序逐步转写。
+要求：每一步单独一行，数学公式用 LaTeX 表示（行内用 $...$）；保持学生原本的写法，包括其中的错误，不要纠正、补充或省略。
+只输出转写内容本身，不要添加编号说明、点评或答案。`
+
+// TranscribeSolution 将学生作答图片转写为步骤列表（每行一步），供与参考解析逐步比对。
+// 未配置 provider/model 时使用占位结果。
+func (s *Service) TranscribeSolution(ctx context.Context, imagePath string) ([]string, error) {
+	if _, err := os.Stat(imagePath); err != nil {
+		return nil, fmt.Errorf("image file: %w", err)
+	}
+	if s.cfg.Provider == "" || s.cfg.Model == "" {
+		return transcribeStub(imagePath)
+	}
+	text, err := callVisionAPI(ctx, s.cfg, s.slots, imagePath, solutionPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("vision api: %w", err)
+	}
+	return SplitSteps(text), nil
+}
+
+// SplitSteps 按行切分作答文本为步骤，去掉空行与 markdown 代码块标记
+func SplitSteps(text string) []string {
+	var steps []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		steps = append(steps, line)
+	}
+	return steps
+}
+
+// transcribeStub 占位实现：返回一段含错误的示例作答，便于联调
+func transcribeStub(_ string) ([]string, error) {
+	return []string{
+		"$x^2 - 5x + 6 = 0$",
+		"$(x-2)(x-3) = 0$",
+		"$x = 2$ 或 $x = -3$",
+	}, nil
+}
@@ -7,13 +7,17 @@ import (
 	"time"
 
 	"github.com/gomath/gomath/internal/config"
+	"github.com/gomath/gomath/internal/media"
+	"github.com/gomath/gomath/internal/ratelimit"
+	"github.com/gomath/gomath/internal/usage"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
-// callVisionAPI 使用 langchaingo 调用 OpenAI 兼容的视觉模型（与 LLM 题目解析同一框架）
-func callVisionAPI(ctx context.Context, cfg config.OCRConfig, imagePath string) (string, error) {
-	data, mime, err := readImageAsBase64(imagePath)
+// callVisionAPI 使用 langchaingo 调用 OpenAI 兼容的视觉模型（与 LLM 题目解析同一框架），prompt 决定识别内容；
+// slots 限制同一上游的并发调用数，可为 nil
+func callVisionAPI(ctx context.Context, cfg config.OCRConfig, slots *ratelimit.Concurrency, imagePath, prompt string) (string, error) {
+	data, mime, err := media.ReadBase64(imagePath)
 	if err != nil {
 		return "", err
 	}
@@ -38,7 +42,7 @@ func callVisionAPI(ctx context.Context, cfg config.OCRConfig, imagePath string)
 	content := llms.MessageContent{
 		Role: llms.ChatMessageTypeHuman,
 		Parts: []llms.ContentPart{
-			llms.TextPart(visionPrompt),
+			llms.TextPart(prompt),
 			llms.ImageURLWithDetailPart(dataURL, "low"),
 		},
 	}
@@ -57,8 +61,13 @@ func callVisionAPI(ctx context.Context, cfg config.OCRConfig, imagePath string)
 				time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
 			}
 		}
+		release, err := slots.Acquire(ctx, cfg.Provider+"|"+cfg.APIBase)
+		if err != nil {
+			return "", err
+		}
 		out, err := llm.GenerateContent(ctx, []llms.MessageContent{content},
 			llms.WithMaxTokens(2048))
+		release()
 		if err != nil {
 			lastErr = err
 			errStr := err.Error()
@@ -71,6 +80,7 @@ func callVisionAPI(ctx context.Context, cfg config.OCRConfig, imagePath string)
 			}
 			break
 		}
+		usage.Observe(ctx, cfg.Provider, cfg.Model, prompt, 1, out)
 		if len(out.Choices) == 0 {
 			lastErr = fmt.Errorf("no choices in response")
 			continue
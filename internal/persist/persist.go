@@ -0,0 +1,150 @@
+// Package persist 存储文件的写入：先写临时文件再原子替换，多次修改合并为一次写入
+package persist
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WriteFile 先写 path 同目录的临时文件再原子替换 path，读者不会看到写了一半的文件；目录不存在时创建。
+// fsync 为 true 时同步文件与目录，断电也不丢失已写入的内容
+func WriteFile(path string, data []byte, perm os.FileMode, fsync bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 替换成功后临时文件已不存在
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if fsync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if fsync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir fsync 目录，确保重命名在断电后仍然生效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Saver 串行化并合并存储的写入：write 在每次写入时取当前全部状态写文件，写入期间到达的修改由下一次写入带上。
+// nil 的 Saver 不写入（仅内存的存储）
+type Saver struct {
+	name  string // 日志前缀
+	delay time.Duration
+	write func() error
+
+	writeMu sync.Mutex  // 串行化写入
+	mu      sync.Mutex  // 保护 dirty、timer、closed
+	dirty   bool        // 有尚未写入文件的修改
+	timer   *time.Timer // 等待合并写入
+	closed  bool        // Close 之后的修改立即写入
+}
+
+// NewSaver 创建 Saver：Changed 后等待 delay 在后台写入一次；name 用于日志
+func NewSaver(name string, delay time.Duration, write func() error) *Saver {
+	return &Saver{name: name, delay: delay, write: write}
+}
+
+// Changed 修改后调用（不得持有存储的锁）：等待 delay 后在后台写入一次，期间的多次修改合并；Close 之后立即写入
+func (s *Saver) Changed() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.dirty = true
+	if s.closed {
+		s.mu.Unlock()
+		if err := s.Flush(); err != nil {
+			log.Printf("[%s] save: %v", s.name, err)
+		}
+		return
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.delay, func() {
+			if err := s.Flush(); err != nil {
+				log.Printf("[%s] save: %v", s.name, err)
+			}
+		})
+	}
+	s.mu.Unlock()
+}
+
+// Save 修改后立即写入，返回时修改已落盘；并发的多次 Save 合并为尽量少的写入
+func (s *Saver) Save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.dirty = true
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+// Flush 立即写入尚未落盘的修改；写入失败时修改仍保留，下次修改或 Flush 时重试
+func (s *Saver) Flush() error {
+	if s == nil {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	dirty := s.dirty
+	s.dirty = false
+	s.mu.Unlock()
+	if !dirty {
+		// 之前的修改已由其他调用者的写入带上
+		return nil
+	}
+	if err := s.write(); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close 写入尚未落盘的修改，之后的修改不再延迟，立即写入；进程退出前调用
+func (s *Saver) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.Flush()
+}
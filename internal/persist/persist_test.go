@@ -0,0 +1,127 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "data.json")
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(data), 0600, true); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Fatalf("content = %q, want %q", got, data)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+	if tmps, _ := filepath.Glob(path + ".tmp-*"); len(tmps) != 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
+
+func TestSaverCoalesces(t *testing.T) {
+	var writes atomic.Int32
+	s := NewSaver("test", time.Hour, func() error {
+		writes.Add(1)
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		s.Changed()
+	}
+	if n := writes.Load(); n != 0 {
+		t.Fatalf("writes before flush = %d, want 0", n)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := writes.Load(); n != 1 {
+		t.Fatalf("writes = %d, want 1", n)
+	}
+	// Close 之后的修改立即写入
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.Changed()
+	if n := writes.Load(); n != 2 {
+		t.Fatalf("writes after close = %d, want 2", n)
+	}
+}
+
+func TestSaverRetriesAfterError(t *testing.T) {
+	fail := true
+	var writes int
+	s := NewSaver("test", 0, func() error {
+		writes++
+		if fail {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	if err := s.Save(); err == nil {
+		t.Fatal("want error")
+	}
+	fail = false
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if writes != 2 {
+		t.Fatalf("writes = %d, want 2 (failed change retried)", writes)
+	}
+}
+
+func TestSaverSaveSerializes(t *testing.T) {
+	var active, overlap atomic.Int32
+	s := NewSaver("test", 0, func() error {
+		if active.Add(1) > 1 {
+			overlap.Store(1)
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+		return nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if overlap.Load() != 0 {
+		t.Error("concurrent writes overlapped")
+	}
+}
+
+func TestNilSaver(t *testing.T) {
+	var s *Saver
+	s.Changed()
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
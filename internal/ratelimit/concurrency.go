@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Concurrency 按上游（provider + api_base）限制同时进行的模型调用数，超出时排队等待。
+// nil 表示不限制。
+type Concurrency struct {
+	n    int
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+// NewConcurrency 创建并发限制，n 为每个上游的并发上限，≤0 时不限制
+func NewConcurrency(n int) *Concurrency {
+	return &Concurrency{n: n, sems: make(map[string]chan struct{})}
+}
+
+// Acquire 占用 upstream 的一个调用名额，ctx 结束前未排到时返回 ctx.Err()；调用结束后须调用 release
+func (c *Concurrency) Acquire(ctx context.Context, upstream string) (release func(), err error) {
+	if c == nil || c.n <= 0 {
+		return func() {}, nil
+	}
+	c.mu.Lock()
+	sem, ok := c.sems[upstream]
+	if !ok {
+		sem = make(chan struct{}, c.n)
+		c.sems[upstream] = sem
+	}
+	c.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+// 空闲桶清理间隔
+const sweepInterval = time.Minute
+
+// bucket 单个客户端的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 按 key（客户端）独立计数的令牌桶限流器
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // 每秒补充的令牌数
+	burst     float64
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewLimiter 创建限流器：每分钟补充 perMinute 个令牌，桶容量 burst
+func NewLimiter(perMinute float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow 消耗 key 的一个令牌；令牌不足时返回 false 及需等待的时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep 定期删除已补满的桶（等同于新客户端），避免按 IP 计数时内存无限增长
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Set 各类接口（upload、ocr、explain、login）的限流器
+type Set struct {
+	limiters map[string]*Limiter
+}
+
+// NewSet 根据统一配置中的 rate_limit 块创建
+func NewSet(cfg config.RateLimitConfig) *Set {
+	s := &Set{limiters: make(map[string]*Limiter)}
+	for _, class := range []string{"upload", "ocr", "explain", "login"} {
+		l := cfg.Class(class)
+		s.limiters[class] = NewLimiter(l.PerMinute, l.Burst)
+	}
+	return s
+}
+
+// Allow 检查客户端 client 在某类接口上是否还有额度，未知类别不限流
+func (s *Set) Allow(class, client string) (bool, time.Duration) {
+	l, ok := s.limiters[class]
+	if !ok {
+		return true, 0
+	}
+	return l.Allow(client)
+}
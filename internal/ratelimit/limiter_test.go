@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(60, 2) // 每秒 1 个，突发 2
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d should be allowed within burst", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != time.Second {
+		t.Fatalf("third request = %v, %v; want rejected with 1s wait", ok, wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("other client should have its own bucket")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, wait := l.Allow("a"); ok || wait != 500*time.Millisecond {
+		t.Fatalf("after 0.5s = %v, %v; want rejected with 0.5s wait", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("token should be refilled after 1s")
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(1)
+	release, err := c.Acquire(context.Background(), "up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := c.Acquire(context.Background(), "other"); err != nil {
+		t.Fatalf("other upstream should not be limited: %v", err)
+	} else {
+		r()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Acquire(ctx, "up"); err == nil {
+		t.Fatal("second acquire should wait until ctx is done")
+	}
+	release()
+	if r, err := c.Acquire(context.Background(), "up"); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	} else {
+		r()
+	}
+	var unlimited *Concurrency
+	if r, err := unlimited.Acquire(context.Background(), "up"); err != nil {
+		t.Fatal(err)
+	} else {
+		r()
+	}
+}
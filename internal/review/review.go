@@ -0,0 +1,109 @@
+package review
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Grade 复习自评
+type Grade string
+
+const (
+	Again Grade = "again" // 没做出来
+	Hard  Grade = "hard"  // 做出来但很吃力
+	Good  Grade = "good"  // 正常做出
+	Easy  Grade = "easy"  // 轻松做出
+)
+
+// ErrInvalidGrade 自评不是 again / hard / good / easy
+var ErrInvalidGrade = errors.New("grade must be again, hard, good or easy")
+
+// ParseGrade 解析自评
+func ParseGrade(s string) (Grade, error) {
+	switch g := Grade(s); g {
+	case Again, Hard, Good, Easy:
+		return g, nil
+	}
+	return "", ErrInvalidGrade
+}
+
+// quality SM-2 的 0-5 分：again 视为回忆失败
+func (g Grade) quality() float64 {
+	switch g {
+	case Again:
+		return 1
+	case Hard:
+		return 3
+	case Easy:
+		return 5
+	}
+	return 4
+}
+
+const (
+	defaultEase = 2.5
+	minEase     = 1.3
+	easyBonus   = 1.3 // easy 时间隔额外放大
+	hardFactor  = 1.2 // hard 时间隔只小幅增长
+	day         = 24 * time.Hour
+)
+
+// Card 一道题的复习状态（SM-2 间隔重复）
+type Card struct {
+	Ease         float64 `json:"ease"`          // 难度系数，越大间隔增长越快，最小 1.3
+	IntervalDays int     `json:"interval_days"` // 当前间隔（天）
+	Reps         int     `json:"reps"`          // 连续做对次数，again 时清零
+	Lapses       int     `json:"lapses"`        // 累计 again 次数
+	Due          int64   `json:"due"`           // 下次复习时间（毫秒）
+	LastReviewed int64   `json:"last_reviewed,omitempty"`
+}
+
+// New 新卡片：默认难度系数，立即到期
+func New(now time.Time) Card {
+	return Card{Ease: defaultEase, Due: now.UnixMilli()}
+}
+
+// Next 记录一次复习并重新排期：again 间隔重置为 1 天并降低难度系数；
+// 做对时前两次间隔为 1、6 天，之后为上次间隔 × 难度系数（hard ×1.2，easy 再 ×1.3），难度系数按 SM-2 公式调整
+func (c Card) Next(g Grade, now time.Time) Card {
+	if c.Ease < minEase {
+		c.Ease = defaultEase
+	}
+	var interval float64
+	switch {
+	case g == Again:
+		c.Reps = 0
+		c.Lapses++
+		interval = 1
+	case c.Reps == 0:
+		interval = 1
+		if g == Easy {
+			interval = 4
+		}
+	case c.Reps == 1:
+		interval = 6
+		if g == Hard {
+			interval = 3
+		}
+	default:
+		prev := float64(max(c.IntervalDays, 1))
+		switch g {
+		case Hard:
+			interval = math.Max(prev+1, prev*hardFactor)
+		case Easy:
+			interval = prev * c.Ease * easyBonus
+		default:
+			interval = prev * c.Ease
+		}
+	}
+	if g != Again {
+		c.Reps++
+	}
+	q := 5 - g.quality()
+	c.Ease = math.Max(minEase, math.Round((c.Ease+0.1-q*(0.08+q*0.02))*100)/100)
+	c.IntervalDays = int(math.Round(interval))
+	c.LastReviewed = now.UnixMilli()
+	c.Due = now.Add(time.Duration(c.IntervalDays) * day).UnixMilli()
+	return c
+}
@@ -0,0 +1,50 @@
+package review
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	c := New(now)
+	if c.Due != now.UnixMilli() || c.Ease != defaultEase {
+		t.Fatalf("new card = %+v", c)
+	}
+	steps := []struct {
+		g        Grade
+		interval int
+		reps     int
+	}{
+		{Good, 1, 1},
+		{Good, 6, 2},
+		{Good, 15, 3}, // 6 × 2.5
+		{Again, 1, 0},
+		{Good, 1, 1},
+		{Hard, 3, 2},
+		{Easy, 7, 3}, // again 后难度系数 2.5→1.96，hard 再降为 1.82：3 × 1.82 × 1.3 ≈ 7.1
+	}
+	for i, st := range steps {
+		c = c.Next(st.g, now)
+		if c.IntervalDays != st.interval || c.Reps != st.reps {
+			t.Fatalf("step %d (%s): interval %d reps %d, want %d %d", i, st.g, c.IntervalDays, c.Reps, st.interval, st.reps)
+		}
+		if want := now.Add(time.Duration(st.interval) * 24 * time.Hour).UnixMilli(); c.Due != want {
+			t.Fatalf("step %d: due %d, want %d", i, c.Due, want)
+		}
+	}
+	if c.Lapses != 1 {
+		t.Errorf("lapses = %d, want 1", c.Lapses)
+	}
+
+	// 反复 again 难度系数不低于下限
+	for range 10 {
+		c = c.Next(Again, now)
+	}
+	if c.Ease != minEase {
+		t.Errorf("ease = %v, want %v", c.Ease, minEase)
+	}
+	if _, err := ParseGrade("perfect"); err != ErrInvalidGrade {
+		t.Errorf("ParseGrade err = %v", err)
+	}
+}
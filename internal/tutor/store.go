@@ -0,0 +1,170 @@
+package tutor
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gomath/gomath/internal/persist"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound 会话不存在
+	ErrNotFound = errors.New("session not found")
+	// ErrStale 提交基于的轮数与会话当前轮数不一致（并发提交或页面过期）
+	ErrStale = errors.New("session changed")
+)
+
+// Turn 学生提交的一步及导师回应
+type Turn struct {
+	Step       string `json:"step"`
+	Accepted   bool   `json:"accepted"`              // 该步是否通过，通过后会话才前进
+	Reply      string `json:"reply"`                 // 鼓励或引导性提问
+	LocalCheck string `json:"local_check,omitempty"` // 本地等价性检查说明，无法本地判断时为空
+	At         int64  `json:"at"`
+}
+
+// Session 一次苏格拉底式辅导会话，关联一条历史记录
+type Session struct {
+	ID          string   `json:"id"`
+	UserID      string   `json:"user_id,omitempty"` // 所属用户，未开启鉴权时为空
+	HistoryID   string   `json:"history_id,omitempty"`
+	ProblemText string   `json:"problem_text"`
+	Steps       []string `json:"steps"` // 已通过的步骤
+	Turns       []Turn   `json:"turns"`
+	Done        bool     `json:"done"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+}
+
+// Store 会话存储，内存 + 文件持久化
+type Store struct {
+	mu       sync.RWMutex
+	saver    *persist.Saver // 串行化写入，仅内存时为 nil
+	sessions map[string]*Session
+	filePath string
+}
+
+// NewStore 创建存储，filePath 为空则仅内存
+func NewStore(filePath string) (*Store, error) {
+	s := &Store{sessions: make(map[string]*Session), filePath: filePath}
+	if filePath != "" {
+		if err := s.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		s.saver = persist.NewSaver("tutor", 0, s.write)
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var list []*Session
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for _, sess := range list {
+		if sess != nil {
+			s.sessions[sess.ID] = sess
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// write 将全部会话写入临时文件后原子替换
+func (s *Store) write() error {
+	s.mu.RLock()
+	snapshot := make([]Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		snapshot = append(snapshot, sess.clone())
+	}
+	s.mu.RUnlock()
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(s.filePath, data, 0644, false)
+}
+
+func (sess *Session) clone() Session {
+	cp := *sess
+	cp.Steps = append(make([]string, 0, len(sess.Steps)), sess.Steps...)
+	cp.Turns = append(make([]Turn, 0, len(sess.Turns)), sess.Turns...)
+	return cp
+}
+
+// Create 新建会话，返回 id
+func (s *Store) Create(sess Session) string {
+	if sess.ID == "" {
+		sess.ID = uuid.New().String()
+	}
+	now := time.Now().UnixMilli()
+	sess.CreatedAt, sess.UpdatedAt = now, now
+	if sess.Steps == nil {
+		sess.Steps = make([]string, 0)
+	}
+	if sess.Turns == nil {
+		sess.Turns = make([]Turn, 0)
+	}
+	s.mu.Lock()
+	s.sessions[sess.ID] = &sess
+	s.mu.Unlock()
+	if err := s.saver.Save(); err != nil {
+		log.Printf("[tutor] save after Create: %v", err)
+	}
+	return sess.ID
+}
+
+// Get 按 id 获取会话副本
+func (s *Store) Get(id string) (*Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	cp := sess.clone()
+	return &cp, true
+}
+
+// AppendTurn 追加一轮对话；turn 通过时同时记入已通过步骤，done 表示已得出最终答案。返回更新后的会话副本。
+// turns 为提交时看到的轮数，与当前轮数不一致时返回 ErrStale，避免并发提交基于同一上一步重复推进
+func (s *Store) AppendTurn(id string, turns int, turn Turn, done bool) (*Session, error) {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if len(sess.Turns) != turns || sess.Done {
+		s.mu.Unlock()
+		return nil, ErrStale
+	}
+	if turn.At == 0 {
+		turn.At = time.Now().UnixMilli()
+	}
+	sess.Turns = append(sess.Turns, turn)
+	if turn.Accepted {
+		sess.Steps = append(sess.Steps, turn.Step)
+		sess.Done = sess.Done || done
+	}
+	sess.UpdatedAt = turn.At
+	cp := sess.clone()
+	s.mu.Unlock()
+	if err := s.saver.Save(); err != nil {
+		log.Printf("[tutor] save after AppendTurn: %v", err)
+	}
+	return &cp, nil
+}
@@ -0,0 +1,34 @@
+package tutor
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendTurnStale(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "tutor.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := s.Create(Session{ProblemText: "x + 1 = 2"})
+	if _, err := s.AppendTurn(id, 0, Turn{Step: "x = 1", Accepted: true}, true); err != nil {
+		t.Fatalf("AppendTurn: %v", err)
+	}
+	// 基于同一轮数的第二次提交已过期
+	if _, err := s.AppendTurn(id, 0, Turn{Step: "x = 1", Accepted: true}, true); !errors.Is(err, ErrStale) {
+		t.Fatalf("stale AppendTurn err = %v, want ErrStale", err)
+	}
+	if _, err := s.AppendTurn("missing", 0, Turn{Step: "x = 1"}, false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing AppendTurn err = %v, want ErrNotFound", err)
+	}
+
+	reloaded, err := NewStore(s.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, ok := reloaded.Get(id)
+	if !ok || len(sess.Turns) != 1 || !sess.Done {
+		t.Fatalf("reloaded session = %+v, %v", sess, ok)
+	}
+}
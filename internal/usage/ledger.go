@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+// Ledger 用量台账：每次模型调用追加一行 JSON（JSON Lines），按价格表计算费用
+type Ledger struct {
+	mu       sync.RWMutex
+	records  []Record
+	filePath string
+	pricing  config.PricingConfig
+}
+
+// NewLedger 创建台账，filePath 为空则仅内存
+func NewLedger(filePath string, pricing config.PricingConfig) (*Ledger, error) {
+	l := &Ledger{filePath: filePath, pricing: pricing}
+	if filePath != "" {
+		if err := l.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *Ledger) load() error {
+	f, err := os.Open(l.filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// 跳过写入中断产生的残行
+			continue
+		}
+		l.records = append(l.records, rec)
+	}
+	return sc.Err()
+}
+
+// Append 按价格表补全费用后记入台账
+func (l *Ledger) Append(rec Record) Record {
+	rec.Cost = l.pricing.Cost(rec.Model, rec.PromptTokens, rec.CompletionTokens)
+	rec.Currency = l.pricing.CurrencyCode()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, rec)
+	if err := l.appendFile(rec); err != nil {
+		log.Printf("[usage] append %s: %v", l.filePath, err)
+	}
+	return rec
+}
+
+func (l *Ledger) appendFile(rec Record) error {
+	if l.filePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.filePath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 支持的分组维度
+var groupFields = map[string]bool{"day": true, "model": true, "endpoint": true}
+
+// ValidGroupBy 判断分组维度是否受支持
+func ValidGroupBy(field string) bool {
+	return groupFields[field]
+}
+
+// Query 用量统计条件：From/To 为空表示不限，GroupBy 为 day、model、endpoint 的组合
+type Query struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+	UserID  string // 非空时只统计该用户的调用
+}
+
+// Group 一组用量统计，未参与分组的维度为空
+type Group struct {
+	Day              string  `json:"day,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Endpoint         string  `json:"endpoint,omitempty"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCalls   int     `json:"estimated_calls"` // 用量为估算值的调用数
+	Cost             float64 `json:"cost"`
+}
+
+// Report 用量统计结果
+type Report struct {
+	Currency string  `json:"currency"`
+	Groups   []Group `json:"groups"`
+	Total    Group   `json:"total"`
+}
+
+// Aggregate 按条件汇总用量，分组按日期、模型、接口排序
+func (l *Ledger) Aggregate(q Query) Report {
+	by := make(map[string]bool, len(q.GroupBy))
+	for _, f := range q.GroupBy {
+		by[f] = true
+	}
+	groups := make(map[string]*Group)
+	report := Report{Currency: l.pricing.CurrencyCode(), Groups: make([]Group, 0)}
+	l.mu.RLock()
+	for _, rec := range l.records {
+		at := time.UnixMilli(rec.At)
+		if (!q.From.IsZero() && at.Before(q.From)) || (!q.To.IsZero() && !at.Before(q.To)) {
+			continue
+		}
+		if q.UserID != "" && rec.UserID != q.UserID {
+			continue
+		}
+		var g Group
+		if by["day"] {
+			g.Day = at.Format("2006-01-02")
+		}
+		if by["model"] {
+			g.Model = rec.Model
+		}
+		if by["endpoint"] {
+			g.Endpoint = rec.Endpoint
+		}
+		key := strings.Join([]string{g.Day, g.Model, g.Endpoint}, "\x00")
+		if groups[key] == nil {
+			groups[key] = &g
+		}
+		groups[key].add(rec)
+		report.Total.add(rec)
+	}
+	l.mu.RUnlock()
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Endpoint < b.Endpoint
+	})
+	return report
+}
+
+func (g *Group) add(rec Record) {
+	g.Calls++
+	g.PromptTokens += rec.PromptTokens
+	g.CompletionTokens += rec.CompletionTokens
+	g.TotalTokens = g.PromptTokens + g.CompletionTokens
+	g.Cost += rec.Cost
+	if rec.Estimated {
+		g.EstimatedCalls++
+	}
+}
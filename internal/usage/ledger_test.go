@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gomath/gomath/internal/config"
+)
+
+var testPricing = config.PricingConfig{Models: map[string]config.ModelPrice{
+	"qwen-plus": {InputPerMillion: 1, OutputPerMillion: 2},
+	"qwen-vl":   {InputPerMillion: 3, OutputPerMillion: 6},
+}}
+
+// day 返回当地时间某日 hour 点的毫秒时间戳（按日分组使用当地日期）
+func day(d, hour int) int64 {
+	return time.Date(2026, 3, d, hour, 0, 0, 0, time.Local).UnixMilli()
+}
+
+func testLedger(t *testing.T, path string) *Ledger {
+	t.Helper()
+	l, err := NewLedger(path, testPricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []Record{
+		{At: day(1, 9), Endpoint: "explain", UserID: "u1", Model: "qwen-plus", PromptTokens: 1000, CompletionTokens: 500},
+		{At: day(1, 23), Endpoint: "ocr", UserID: "u2", Model: "qwen-vl", PromptTokens: 200, CompletionTokens: 100, Estimated: true},
+		{At: day(2, 0), Endpoint: "explain", UserID: "u1", Model: "qwen-plus", PromptTokens: 3000, CompletionTokens: 1000},
+		{At: day(3, 12), Endpoint: "explain", UserID: "u2", Model: "qwen-vl", PromptTokens: 100, CompletionTokens: 50},
+	} {
+		l.Append(rec)
+	}
+	return l
+}
+
+// summarize 只保留分组维度与调用数、token 数，便于比较
+func summarize(groups []Group) []Group {
+	out := make([]Group, len(groups))
+	for i, g := range groups {
+		out[i] = Group{Day: g.Day, Model: g.Model, Endpoint: g.Endpoint, Calls: g.Calls, PromptTokens: g.PromptTokens, CompletionTokens: g.CompletionTokens, TotalTokens: g.TotalTokens, EstimatedCalls: g.EstimatedCalls}
+	}
+	return out
+}
+
+func TestAppendCost(t *testing.T) {
+	l := testLedger(t, "")
+	rec := l.Append(Record{Model: "qwen-plus", PromptTokens: 1_000_000, CompletionTokens: 1_000_000})
+	if rec.Cost != 3 || rec.Currency != "CNY" {
+		t.Errorf("cost = %v %s, want 3 CNY", rec.Cost, rec.Currency)
+	}
+	rec = l.Append(Record{Model: "unknown", PromptTokens: 100})
+	if rec.Cost != 0 {
+		t.Errorf("unpriced model cost = %v, want 0", rec.Cost)
+	}
+}
+
+func TestAggregateGroupBy(t *testing.T) {
+	l := testLedger(t, "")
+	tests := []struct {
+		name    string
+		groupBy []string
+		want    []Group
+	}{
+		{"none", nil, []Group{
+			{Calls: 4, PromptTokens: 4300, CompletionTokens: 1650, TotalTokens: 5950, EstimatedCalls: 1},
+		}},
+		{"day", []string{"day"}, []Group{
+			{Day: "2026-03-01", Calls: 2, PromptTokens: 1200, CompletionTokens: 600, TotalTokens: 1800, EstimatedCalls: 1},
+			{Day: "2026-03-02", Calls: 1, PromptTokens: 3000, CompletionTokens: 1000, TotalTokens: 4000},
+			{Day: "2026-03-03", Calls: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+		}},
+		{"model", []string{"model"}, []Group{
+			{Model: "qwen-plus", Calls: 2, PromptTokens: 4000, CompletionTokens: 1500, TotalTokens: 5500},
+			{Model: "qwen-vl", Calls: 2, PromptTokens: 300, CompletionTokens: 150, TotalTokens: 450, EstimatedCalls: 1},
+		}},
+		{"endpoint", []string{"endpoint"}, []Group{
+			{Endpoint: "explain", Calls: 3, PromptTokens: 4100, CompletionTokens: 1550, TotalTokens: 5650},
+			{Endpoint: "ocr", Calls: 1, PromptTokens: 200, CompletionTokens: 100, TotalTokens: 300, EstimatedCalls: 1},
+		}},
+		{"day and model", []string{"day", "model"}, []Group{
+			{Day: "2026-03-01", Model: "qwen-plus", Calls: 1, PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+			{Day: "2026-03-01", Model: "qwen-vl", Calls: 1, PromptTokens: 200, CompletionTokens: 100, TotalTokens: 300, EstimatedCalls: 1},
+			{Day: "2026-03-02", Model: "qwen-plus", Calls: 1, PromptTokens: 3000, CompletionTokens: 1000, TotalTokens: 4000},
+			{Day: "2026-03-03", Model: "qwen-vl", Calls: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := l.Aggregate(Query{GroupBy: tt.groupBy})
+			if got := summarize(r.Groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groups = %+v\nwant %+v", got, tt.want)
+			}
+			if r.Total.Calls != 4 || r.Total.TotalTokens != 5950 {
+				t.Errorf("total = %+v", r.Total)
+			}
+		})
+	}
+}
+
+func TestAggregateCost(t *testing.T) {
+	l := testLedger(t, "")
+	r := l.Aggregate(Query{GroupBy: []string{"model"}})
+	// qwen-plus: 4000×1 + 1500×2；qwen-vl: 300×3 + 150×6（每百万 token）
+	want := map[string]float64{"qwen-plus": 0.007, "qwen-vl": 0.0018}
+	for _, g := range r.Groups {
+		if math.Abs(g.Cost-want[g.Model]) > 1e-12 {
+			t.Errorf("%s cost = %v, want %v", g.Model, g.Cost, want[g.Model])
+		}
+	}
+	if math.Abs(r.Total.Cost-0.0088) > 1e-12 {
+		t.Errorf("total cost = %v, want 0.0088", r.Total.Cost)
+	}
+	if r.Currency != "CNY" {
+		t.Errorf("currency = %q", r.Currency)
+	}
+}
+
+func TestAggregateBounds(t *testing.T) {
+	l := testLedger(t, "")
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"from inclusive", time.UnixMilli(day(2, 0)), time.Time{}, 2},
+		{"to exclusive", time.Time{}, time.UnixMilli(day(2, 0)), 2},
+		{"range", time.UnixMilli(day(1, 10)), time.UnixMilli(day(3, 0)), 2},
+		{"empty range", time.UnixMilli(day(4, 0)), time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := l.Aggregate(Query{From: tt.from, To: tt.to})
+			if r.Total.Calls != tt.want {
+				t.Errorf("calls = %d, want %d", r.Total.Calls, tt.want)
+			}
+			if tt.want == 0 && (r.Groups == nil || len(r.Groups) != 0) {
+				t.Errorf("groups = %#v, want empty slice", r.Groups)
+			}
+		})
+	}
+	if r := l.Aggregate(Query{UserID: "u2"}); r.Total.Calls != 2 || r.Total.PromptTokens != 300 {
+		t.Errorf("user filter total = %+v", r.Total)
+	}
+}
+
+func TestLedgerReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage", "usage.jsonl")
+	before := testLedger(t, path).Aggregate(Query{GroupBy: []string{"day", "model", "endpoint"}})
+
+	// 写入中断留下的残行在加载时跳过
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"at":17`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l, err := NewLedger(path, testPricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := l.Aggregate(Query{GroupBy: []string{"day", "model", "endpoint"}})
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("after reload = %+v\nwant %+v", after, before)
+	}
+	if after.Total.Calls != 4 {
+		t.Errorf("calls after reload = %d, want 4", after.Total.Calls)
+	}
+}
+
+func TestNewLedgerMissingFile(t *testing.T) {
+	l, err := NewLedger(filepath.Join(t.TempDir(), "none.jsonl"), testPricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := l.Aggregate(Query{}); r.Total.Calls != 0 {
+		t.Errorf("calls = %d, want 0", r.Total.Calls)
+	}
+}
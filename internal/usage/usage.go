@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ImageTokensEstimate 低清晰度（detail=low）图片按固定 token 数估算
+const ImageTokensEstimate = 85
+
+// Record 一次模型调用的用量记录
+type Record struct {
+	At               int64   `json:"at"`
+	Endpoint         string  `json:"endpoint"`          // 发起调用的接口，如 explain、submit
+	UserID           string  `json:"user_id,omitempty"` // 发起调用的用户，未开启鉴权时为空
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Estimated        bool    `json:"estimated,omitempty"` // 上游未返回用量，按 tiktoken 估算
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency,omitempty"`
+}
+
+// Summary 一个任务（可能包含多次模型调用）的用量汇总
+type Summary struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency,omitempty"`
+	Estimated        bool    `json:"estimated,omitempty"` // 至少一次调用的用量为估算值
+}
+
+// Sink 用量记录落地（如台账），返回补全费用后的记录
+type Sink interface {
+	Append(rec Record) Record
+}
+
+// Recorder 收集一次请求内的模型调用用量，经 WithRecorder 挂在 context 上
+type Recorder struct {
+	mu       sync.Mutex
+	sink     Sink
+	endpoint string
+	userID   string
+	sum      Summary
+}
+
+type recorderKey struct{}
+
+// WithRecorder 返回挂载了用量收集器的 context；模型调用通过 Observe 上报，sink 可为 nil（仅汇总不落地），userID 为发起请求的用户
+func WithRecorder(ctx context.Context, sink Sink, endpoint, userID string) (context.Context, *Recorder) {
+	rec := &Recorder{sink: sink, endpoint: endpoint, userID: userID}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// Summary 返回汇总，未发生模型调用（如命中缓存）时返回 nil
+func (r *Recorder) Summary() *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sum.Calls == 0 {
+		return nil
+	}
+	cp := r.sum
+	return &cp
+}
+
+func (r *Recorder) add(rec Record) {
+	rec.Endpoint = r.endpoint
+	rec.UserID = r.userID
+	if r.sink != nil {
+		rec = r.sink.Append(rec)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sum.Calls++
+	r.sum.PromptTokens += rec.PromptTokens
+	r.sum.CompletionTokens += rec.CompletionTokens
+	r.sum.TotalTokens = r.sum.PromptTokens + r.sum.CompletionTokens
+	r.sum.Cost += rec.Cost
+	r.sum.Currency = rec.Currency
+	r.sum.Estimated = r.sum.Estimated || rec.Estimated
+}
+
+// Observe 上报一次模型调用：优先使用响应中的 token 用量，缺失时按 prompt 文本、图片数与输出文本估算。
+// ctx 上没有 Recorder 时忽略。
+func Observe(ctx context.Context, provider, model, prompt string, images int, resp *llms.ContentResponse) {
+	rec, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok || resp == nil {
+		return
+	}
+	r := Record{At: time.Now().UnixMilli(), Provider: provider, Model: model}
+	var output string
+	if len(resp.Choices) > 0 {
+		output = resp.Choices[0].Content
+		r.PromptTokens = intInfo(resp.Choices[0].GenerationInfo, "PromptTokens")
+		r.CompletionTokens = intInfo(resp.Choices[0].GenerationInfo, "CompletionTokens")
+	}
+	if r.PromptTokens == 0 && r.CompletionTokens == 0 {
+		r.PromptTokens = Estimate(prompt) + images*ImageTokensEstimate
+		r.CompletionTokens = Estimate(output)
+		r.Estimated = true
+	}
+	rec.add(r)
+}
+
+func intInfo(info map[string]any, key string) int {
+	switch v := info[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+var (
+	encOnce sync.Once
+	encMu   sync.RWMutex
+	enc     *tiktoken.Tiktoken
+)
+
+// LoadEncoding 在后台加载 tiktoken 编码表（首次可能需要下载），服务启动时调用可避免首批请求使用粗估
+func LoadEncoding() {
+	encOnce.Do(func() {
+		go func() {
+			e, err := tiktoken.GetEncoding("cl100k_base")
+			if err != nil {
+				log.Printf("[usage] load tiktoken encoding: %v, using rough estimate", err)
+				return
+			}
+			encMu.Lock()
+			enc = e
+			encMu.Unlock()
+		}()
+	})
+}
+
+// Estimate 估算文本的 token 数（cl100k_base 编码）。
+// 编码表加载完成前按字符粗估：汉字等每字约 1 token，其余约 4 字符 1 token。
+func Estimate(text string) int {
+	if text == "" {
+		return 0
+	}
+	LoadEncoding()
+	encMu.RLock()
+	e := enc
+	encMu.RUnlock()
+	if e != nil {
+		return len(e.Encode(text, nil, nil))
+	}
+	var wide, other int
+	for _, r := range text {
+		if r > unicode.MaxASCII && !unicode.IsSpace(r) {
+			wide++
+		} else {
+			other++
+		}
+	}
+	return wide + (other+3)/4
+}
@@ -0,0 +1,105 @@
+package usage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// roughEstimate 阻止后台加载 tiktoken 编码表（需要下载），使 Estimate 固定使用粗估
+func roughEstimate(t *testing.T) {
+	t.Helper()
+	encOnce.Do(func() {})
+	encMu.RLock()
+	defer encMu.RUnlock()
+	if enc != nil {
+		t.Skip("tiktoken encoding already loaded")
+	}
+}
+
+func TestEstimateRough(t *testing.T) {
+	roughEstimate(t)
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"解方程", 3},
+		{"解方程 2x+4=10", 3 + 2}, // 3 个汉字 + 8 个 ASCII 字符
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.text); got != tt.want {
+			t.Errorf("Estimate(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+type sinkFunc func(Record) Record
+
+func (f sinkFunc) Append(rec Record) Record { return f(rec) }
+
+func TestObserveReportedUsage(t *testing.T) {
+	var got []Record
+	sink := sinkFunc(func(rec Record) Record {
+		rec.Cost = 0.5
+		rec.Currency = "CNY"
+		got = append(got, rec)
+		return rec
+	})
+	ctx, rec := WithRecorder(context.Background(), sink, "explain", "u1")
+	Observe(ctx, "openai", "qwen-plus", "prompt", 0, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:        "answer",
+		GenerationInfo: map[string]any{"PromptTokens": 120, "CompletionTokens": float64(30)},
+	}}})
+	if len(got) != 1 {
+		t.Fatalf("sink records = %d, want 1", len(got))
+	}
+	r := got[0]
+	if r.Endpoint != "explain" || r.UserID != "u1" || r.Provider != "openai" || r.Model != "qwen-plus" {
+		t.Errorf("record = %+v", r)
+	}
+	if r.PromptTokens != 120 || r.CompletionTokens != 30 || r.Estimated {
+		t.Errorf("tokens = %d/%d estimated=%v, want 120/30 reported", r.PromptTokens, r.CompletionTokens, r.Estimated)
+	}
+	sum := rec.Summary()
+	if sum == nil || sum.Calls != 1 || sum.TotalTokens != 150 || sum.Cost != 0.5 || sum.Currency != "CNY" || sum.Estimated {
+		t.Errorf("summary = %+v", sum)
+	}
+}
+
+func TestObserveEstimatesMissingUsage(t *testing.T) {
+	roughEstimate(t)
+	ctx, rec := WithRecorder(context.Background(), nil, "ocr", "")
+	// 上游未返回 token 用量：按 prompt、图片数与输出估算
+	Observe(ctx, "openai", "qwen-vl", "abcdefgh", 2, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content: "解方程",
+	}}})
+	Observe(ctx, "openai", "qwen-vl", "abcd", 0, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:        "x",
+		GenerationInfo: map[string]any{"PromptTokens": int64(10), "CompletionTokens": 5},
+	}}})
+	sum := rec.Summary()
+	if sum == nil {
+		t.Fatal("summary = nil")
+	}
+	wantPrompt := 2 + 2*ImageTokensEstimate + 10
+	if sum.Calls != 2 || sum.PromptTokens != wantPrompt || sum.CompletionTokens != 3+5 {
+		t.Errorf("summary = %+v, want prompt %d completion 8", sum, wantPrompt)
+	}
+	if !sum.Estimated {
+		t.Error("summary not marked estimated")
+	}
+}
+
+func TestObserveWithoutRecorder(t *testing.T) {
+	// 未挂 Recorder 或响应为空时忽略，不应 panic
+	Observe(context.Background(), "openai", "qwen-plus", "prompt", 0, &llms.ContentResponse{})
+	ctx, rec := WithRecorder(context.Background(), nil, "explain", "")
+	Observe(ctx, "openai", "qwen-plus", "prompt", 0, nil)
+	if sum := rec.Summary(); sum != nil {
+		t.Errorf("summary = %+v, want nil", sum)
+	}
+}
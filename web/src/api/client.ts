@@ -1,10 +1,21 @@
 const BASE = '/api'
 
-export type UploadResponse = { path: string }
-export type SubmitResponse = { problem_text: string }
-export type ExplainResponse = { task_id: string }
+export type UploadResponse = { path: string; mime?: string; width?: number; height?: number; duplicate?: boolean }
+export type SubmitResponse = { problem_text: string; cached?: boolean; usage?: Usage }
+/** history_id 为记录结果的历史条目（服务端写入结果与状态），version_id 为本次结果在该条目中的解析版本 */
+export type ExplainResponse = { task_id: string; history_id?: string; version_id?: string; cached?: boolean }
 export type StepResponse = { title: string; content: string; image_url?: string }
-export type ResultResponse = { steps: StepResponse[] }
+/** 模型用量与费用（服务端按价格表计算） */
+export type Usage = {
+  calls: number
+  prompt_tokens: number
+  completion_tokens: number
+  total_tokens: number
+  cost: number
+  currency?: string
+  estimated?: boolean
+}
+export type ResultResponse = { problem?: string; steps: StepResponse[]; answer?: string; usage?: Usage }
 
 export async function uploadImage(file: File): Promise<UploadResponse> {
   const form = new FormData()
@@ -37,15 +48,17 @@ export async function submitImage(imagePath: string): Promise<SubmitResponse> {
 /** 解析接口可能较慢（多模态/长文本），给足时间避免前端先超时 */
 const EXPLAIN_TIMEOUT_MS = 4 * 60 * 1000
 
-async function explainFetch(body: object): Promise<Response> {
+/** 请求解析类接口：body 为 FormData 时按表单提交，否则按 JSON */
+async function explainFetch(path: string, body: object | FormData): Promise<Response> {
   const ac = new AbortController()
   const t = setTimeout(() => ac.abort(), EXPLAIN_TIMEOUT_MS)
   let r: Response
   try {
-    r = await fetch(`${BASE}/explain`, {
+    r = await fetch(`${BASE}${path}`, {
       method: 'POST',
-      headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify(body),
+      ...(body instanceof FormData
+        ? { body }
+        : { headers: { 'Content-Type': 'application/json' }, body: JSON.stringify(body) }),
       signal: ac.signal,
     })
   } catch (e) {
@@ -66,14 +79,56 @@ async function explainFetch(body: object): Promise<Response> {
   return r
 }
 
-export async function startExplain(problemText: string): Promise<ExplainResponse> {
-  const r = await explainFetch({ problem_text: problemText })
+/** regenerate 为 true 时忽略缓存重新生成；传 historyId 时结果记录到该条历史，否则服务端新建一条 */
+export async function startExplain(problemText: string, regenerate = false, historyId?: string): Promise<ExplainResponse> {
+  const r = await explainFetch('/explain', { problem_text: problemText, regenerate, history_id: historyId })
   return r.json()
 }
 
 /** 直接根据已上传的题目图片让模型解析（不经过 OCR 识图） */
-export async function startExplainFromImage(imagePath: string): Promise<ExplainResponse> {
-  const r = await explainFetch({ image_path: imagePath })
+export async function startExplainFromImage(imagePath: string, regenerate = false, historyId?: string): Promise<ExplainResponse> {
+  const r = await explainFetch('/explain', { image_path: imagePath, regenerate, history_id: historyId })
+  return r.json()
+}
+
+/** 重新解析一条历史（忽略缓存）：题目取自条目（文字或图片及选定区域），新结果作为新版本并设为当前版本 */
+export async function regenerateHistoryItem(historyId: string): Promise<ExplainResponse> {
+  const r = await explainFetch(`/history/${historyId}/regenerate`, {})
+  return r.json()
+}
+
+/** 一键解题选项：mode 为图片处理方式（默认 auto：配置了识图时先识图），其余阶段默认开启（regenerate 除外） */
+export type SolveOptions = {
+  mode?: 'auto' | 'ocr' | 'vision'
+  region?: { x: number; y: number; w: number; h: number; rotate?: number }
+  explain?: boolean
+  step_images?: boolean
+  history?: boolean
+  regenerate?: boolean
+  tags?: string[]
+}
+export type SolveResponse = {
+  mode: 'text' | 'ocr' | 'vision'
+  image_path?: string
+  problem_text?: string
+  task_id?: string
+  history_id?: string
+  cached?: boolean
+  result?: ResultResponse
+  usage?: Usage
+}
+
+/** 一键解题：上传、识图、解析、讲解图与历史记录一次完成 */
+export async function solve(input: File | string, options: SolveOptions = {}): Promise<SolveResponse> {
+  const form = new FormData()
+  if (typeof input === 'string') form.append('text', input)
+  else form.append('file', input)
+  for (const [k, v] of Object.entries(options)) {
+    if (v === undefined) continue
+    if (k === 'region') form.append(k, JSON.stringify(v))
+    else form.append(k, Array.isArray(v) ? v.join(',') : String(v))
+  }
+  const r = await explainFetch('/solve', form)
   return r.json()
 }
 
@@ -83,33 +138,126 @@ export async function getResult(taskId: string): Promise<ResultResponse> {
   return r.json()
 }
 
+export type ExportFormat = 'md' | 'tex' | 'html'
+
+/** 解析结果导出下载地址：Markdown / LaTeX / 可打印 HTML */
+export function resultExportUrl(taskId: string, format: ExportFormat): string {
+  return `${BASE}/result/${taskId}/export?format=${format}`
+}
+
 // 解析历史（存后端）
-export type HistoryStep = { title: string; content: string; image_url?: string }
-export type HistoryResult = { steps: HistoryStep[] }
+/** 解析状态：解析中 / 完成 / 失败，未解析过时为空 */
+export type HistoryStatus = 'pending' | 'done' | 'failed'
 export type HistoryItem = {
   id: string
+  user_id?: string
   type: 'upload' | 'text'
   path?: string
   text?: string
+  problem_no?: string
+  region?: { x: number; y: number; w: number; h: number; rotate?: number }
   at: number
-  result?: HistoryResult | null
+  created_at?: number
+  updated_at?: number
+  tags?: string[]
+  pinned?: boolean
+  mistake?: Mistake
+  review?: ReviewCard
+  status?: HistoryStatus
+  result?: ResultResponse | null
   task_id?: string
+  model?: string
+  error?: string
+  versions?: HistoryVersion[]
+  preferred?: string
 }
 
-export async function listHistory(): Promise<HistoryItem[]> {
-  const r = await fetch(`${BASE}/history`, { cache: 'no-store' })
+/** 一次解析的结果版本；条目的 result 为当前采用的版本 */
+export type HistoryVersion = { id: string; result: ResultResponse; task_id?: string; model?: string; created_at: number }
+
+/** 选择当前采用的解析版本 */
+export async function setPreferredVersion(id: string, versionId: string): Promise<void> {
+  const r = await fetch(`${BASE}/history/${id}/preferred`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ version_id: versionId }),
+  })
+  if (!r.ok) throw new Error(await r.text() || '切换版本失败')
+}
+
+export type StepDiff = {
+  op: 'same' | 'changed' | 'added' | 'removed'
+  old: number
+  new: number
+  from?: StepResponse
+  to?: StepResponse
+}
+export type VersionDiff = {
+  from: string
+  to: string
+  steps: StepDiff[]
+  answer_from?: string
+  answer_to?: string
+  answer_changed: boolean
+}
+
+/** 逐步对比两个解析版本：to 默认为当前版本，from 默认为 to 的上一个版本 */
+export async function diffVersions(id: string, from?: string, to?: string): Promise<VersionDiff> {
+  const params = new URLSearchParams()
+  if (from) params.set('from', from)
+  if (to) params.set('to', to)
+  const qs = params.toString()
+  const r = await fetch(`${BASE}/history/${id}/diff${qs ? `?${qs}` : ''}`)
+  if (!r.ok) throw new Error(await r.text() || '对比版本失败')
+  return r.json()
+}
+
+/** 历史检索条件，均可选；from/to 为 YYYY-MM-DD */
+export type HistoryQuery = {
+  q?: string
+  type?: 'upload' | 'text'
+  from?: string
+  to?: string
+  has_result?: boolean
+  tags?: string[]
+  sort?: 'newest' | 'oldest'
+  cursor?: string
+  limit?: number
+}
+export type HistoryPage = { items: HistoryItem[]; next_cursor?: string }
+
+export async function searchHistory(query: HistoryQuery = {}): Promise<HistoryPage> {
+  const params = new URLSearchParams()
+  for (const [k, v] of Object.entries(query)) {
+    if (v === undefined || v === '') continue
+    params.set(k, Array.isArray(v) ? v.join(',') : String(v))
+  }
+  const qs = params.toString()
+  const r = await fetch(`${BASE}/history${qs ? `?${qs}` : ''}`, { cache: 'no-store' })
   if (!r.ok) throw new Error(await r.text() || '获取历史失败')
   const data = await r.json()
-  const list = data.items ?? data.Items ?? []
-  return Array.isArray(list) ? list : []
+  return { items: Array.isArray(data.items) ? data.items : [], next_cursor: data.next_cursor }
 }
 
+export async function listHistory(): Promise<HistoryItem[]> {
+  return (await searchHistory()).items
+}
+
+export async function setHistoryTags(id: string, tags: string[]): Promise<void> {
+  const r = await fetch(`${BASE}/history/${id}/tags`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ tags }),
+  })
+  if (!r.ok) throw new Error(await r.text() || '设置标签失败')
+}
+
+/** 新增历史，条目时间由服务端赋值并返回 */
 export async function createHistoryItem(item: {
   type: 'upload' | 'text'
   path?: string
   text?: string
-  at: number
-}): Promise<{ id: string }> {
+}): Promise<{ id: string; at: number }> {
   const r = await fetch(`${BASE}/history`, {
     method: 'POST',
     headers: { 'Content-Type': 'application/json' },
@@ -119,17 +267,76 @@ export async function createHistoryItem(item: {
   return r.json()
 }
 
-export async function updateHistoryResult(
+/** 置顶/取消置顶：置顶条目不会被保留策略清理 */
+/** 错题原因：概念不清 / 计算错误 / 审题不清 / 方法错误 / 其他 */
+export type MistakeCategory = 'concept' | 'calculation' | 'careless' | 'method' | 'other'
+export type Mistake = { note?: string; wrong_answer?: string; category?: MistakeCategory; at: number }
+
+/** 错题列表，参数同历史检索，另可按分类筛选 */
+export async function searchMistakes(query: HistoryQuery & { category?: MistakeCategory } = {}): Promise<HistoryPage> {
+  const params = new URLSearchParams()
+  for (const [k, v] of Object.entries(query)) {
+    if (v === undefined || v === '') continue
+    params.set(k, Array.isArray(v) ? v.join(',') : String(v))
+  }
+  const qs = params.toString()
+  const r = await fetch(`${BASE}/mistakes${qs ? `?${qs}` : ''}`, { cache: 'no-store' })
+  if (!r.ok) throw new Error(await r.text() || '获取错题失败')
+  const data = await r.json()
+  return { items: Array.isArray(data.items) ? data.items : [], next_cursor: data.next_cursor }
+}
+
+/** 加入错题本或更新错题信息；传 tags 时整体替换条目标签 */
+export async function setMistake(
   id: string,
-  result: ResultResponse,
-  taskId: string
+  m: { note?: string; wrong_answer?: string; category?: MistakeCategory; tags?: string[] }
 ): Promise<void> {
-  const r = await fetch(`${BASE}/history/${id}`, {
-    method: 'PATCH',
+  const r = await fetch(`${BASE}/mistakes/${id}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(m),
+  })
+  if (!r.ok) throw new Error(await r.text() || '加入错题本失败')
+}
+
+export async function removeMistake(id: string): Promise<void> {
+  const r = await fetch(`${BASE}/mistakes/${id}`, { method: 'DELETE' })
+  if (!r.ok) throw new Error(await r.text() || '移出错题本失败')
+}
+
+/** 间隔复习状态（SM-2） */
+export type ReviewCard = {
+  ease: number
+  interval_days: number
+  reps: number
+  lapses: number
+  due: number
+  last_reviewed?: number
+}
+export type ReviewGrade = 'again' | 'hard' | 'good' | 'easy'
+
+/** 今日待复习：复习到期的题与尚未复习过的错题，按到期先后 */
+export async function getReviewDue(limit?: number): Promise<{ items: HistoryItem[]; total: number }> {
+  const r = await fetch(`${BASE}/review/due${limit ? `?limit=${limit}` : ''}`, { cache: 'no-store' })
+  if (!r.ok) throw new Error(await r.text() || '获取复习计划失败')
+  const data = await r.json()
+  return { items: Array.isArray(data.items) ? data.items : [], total: data.total ?? 0 }
+}
+
+/** 记录一次复习自评并返回新的排期 */
+export async function gradeReview(id: string, grade: ReviewGrade): Promise<ReviewCard> {
+  const r = await fetch(`${BASE}/review/${id}`, {
+    method: 'POST',
     headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ result, task_id: taskId }),
+    body: JSON.stringify({ grade }),
   })
-  if (!r.ok) throw new Error(await r.text() || '更新历史失败')
+  if (!r.ok) throw new Error(await r.text() || '记录复习失败')
+  return r.json()
+}
+
+export async function setHistoryPinned(id: string, pinned: boolean): Promise<void> {
+  const r = await fetch(`${BASE}/history/${id}/pin`, { method: pinned ? 'PUT' : 'DELETE' })
+  if (!r.ok) throw new Error(await r.text() || '置顶失败')
 }
 
 export async function deleteHistoryItem(id: string): Promise<void> {
@@ -137,9 +344,73 @@ export async function deleteHistoryItem(id: string): Promise<void> {
   if (!r.ok) throw new Error(await r.text() || '删除失败')
 }
 
-export async function findLatestUploadHistoryId(path: string): Promise<string | null> {
-  const r = await fetch(`${BASE}/history/find-upload?path=${encodeURIComponent(path)}`)
-  if (!r.ok) return null
+/** 当前登录用户（服务端开启鉴权时） */
+export type User = { id: string; username: string; admin: boolean }
+
+/** 登录：会话令牌由服务端写入 HttpOnly Cookie，之后的请求自动携带 */
+export async function login(username: string, password: string): Promise<User> {
+  const r = await fetch(`${BASE}/auth/login`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username, password }),
+  })
+  if (!r.ok) throw new Error(await r.text() || '登录失败')
   const data = await r.json()
-  return data.id ?? null
+  return data.user
+}
+
+export async function logout(): Promise<void> {
+  await fetch(`${BASE}/auth/logout`, { method: 'POST' })
+}
+
+/** 当前用户；未登录返回 null，服务端未开启鉴权时返回 undefined */
+export async function getMe(): Promise<User | null | undefined> {
+  const r = await fetch(`${BASE}/auth/me`, { cache: 'no-store' })
+  if (r.status === 503) return undefined
+  if (!r.ok) return null
+  return r.json()
+}
+
+/** 导出历史归档（zip）的下载地址，可直接用于 <a href download> */
+export const historyExportUrl = `${BASE}/history/export`
+
+export type WorksheetOptions = {
+  /** 按给定顺序选题；与 tag 二选一 */
+  ids?: string[]
+  /** 选取该标签下的全部历史 */
+  tag?: string
+  title?: string
+  /** 答案卷：附解析与答案 */
+  answers?: boolean
+  numbering?: boolean
+  /** 每题下方留白（厘米） */
+  space?: number
+  shuffle?: boolean
+  /** 打乱题序的种子，练习卷与答案卷传同一值即题序一致 */
+  seed?: number
+}
+
+/** 可打印练习卷 / 答案卷地址（HTML，在新窗口打开后打印） */
+export function worksheetUrl(opts: WorksheetOptions): string {
+  const p = new URLSearchParams()
+  if (opts.ids?.length) p.set('ids', opts.ids.join(','))
+  if (opts.tag) p.set('tag', opts.tag)
+  if (opts.title) p.set('title', opts.title)
+  if (opts.answers) p.set('answers', 'true')
+  if (opts.numbering === false) p.set('numbering', 'false')
+  if (opts.space != null) p.set('space', String(opts.space))
+  if (opts.shuffle) p.set('shuffle', 'true')
+  if (opts.seed != null) p.set('seed', String(opts.seed))
+  return `${BASE}/history/worksheet?${p}`
+}
+
+export type HistoryImportResponse = { imported: number; skipped: number; images: number }
+
+/** 导入历史归档：图片与历史合并到当前账号，重复条目自动跳过 */
+export async function importHistory(file: File): Promise<HistoryImportResponse> {
+  const form = new FormData()
+  form.append('file', file)
+  const r = await fetch(`${BASE}/history/import`, { method: 'POST', body: form })
+  if (!r.ok) throw new Error(await r.text() || '导入失败')
+  return r.json()
 }